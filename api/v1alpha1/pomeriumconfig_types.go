@@ -0,0 +1,61 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ConfigConditionAccepted is the condition type reporting whether a PomeriumConfig is used as the base configuration
+const ConfigConditionAccepted = "Accepted"
+
+// PomeriumConfigSpec holds the base Pomerium configuration
+type PomeriumConfigSpec struct {
+	// Options are Pomerium configuration options in their configuration file form, e.g. `authenticate_service_url`.
+	// Policies generated from resources are added to them.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Options runtime.RawExtension `json:"options"`
+}
+
+// PomeriumConfigStatus reports the configuration last rendered from a PomeriumConfig
+type PomeriumConfigStatus struct {
+	// ObservedGeneration is the generation of the spec last reconciled
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Checksum is the SHA256 checksum of the last saved Pomerium configuration
+	// +optional
+	Checksum string `json:"checksum,omitempty"`
+	// LastSaved is the time the Pomerium configuration was last saved
+	// +optional
+	LastSaved *metav1.Time `json:"lastSaved,omitempty"`
+	// Conditions hold the Accepted condition of the configuration
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Checksum",type=string,JSONPath=`.status.checksum`
+// +kubebuilder:printcolumn:name="Last Saved",type=date,JSONPath=`.status.lastSaved`
+
+// PomeriumConfig is the base configuration of Pomerium
+type PomeriumConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PomeriumConfigSpec   `json:"spec,omitempty"`
+	Status PomeriumConfigStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// PomeriumConfigList contains a list of PomeriumConfig
+type PomeriumConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PomeriumConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PomeriumConfig{}, &PomeriumConfigList{})
+}
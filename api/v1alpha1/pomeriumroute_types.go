@@ -0,0 +1,103 @@
+package v1alpha1
+
+import (
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// RouteConditionAccepted is the condition type reporting whether a PomeriumRoute was added to the Pomerium configuration
+const RouteConditionAccepted = "Accepted"
+
+// RouteBackend is an upstream of a PomeriumRoute
+type RouteBackend struct {
+	// Service references a Service port in the namespace of the PomeriumRoute
+	Service networkingv1.IngressServiceBackend `json:"service"`
+}
+
+// PomeriumRouteSpec defines the Pomerium policies of a route
+type PomeriumRouteSpec struct {
+	// From is the external URL of the route, e.g. https://app.example.com
+	From string `json:"from"`
+	// To is the Service requests are routed to.  Pomerium policies have a single upstream, so exactly one backend is
+	// accepted.
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=1
+	To []RouteBackend `json:"to"`
+	// BackendProtocol is the scheme used to reach the backends.  Defaults to http.
+	// +kubebuilder:validation:Enum=http;https
+	// +optional
+	BackendProtocol string `json:"backendProtocol,omitempty"`
+
+	// Path matches requests for exactly this path
+	// +optional
+	Path string `json:"path,omitempty"`
+	// Prefix matches requests with paths starting with this prefix
+	// +optional
+	Prefix string `json:"prefix,omitempty"`
+	// Regex matches requests with paths matching this regular expression
+	// +optional
+	Regex string `json:"regex,omitempty"`
+
+	// +optional
+	AllowedUsers []string `json:"allowedUsers,omitempty"`
+	// +optional
+	AllowedGroups []string `json:"allowedGroups,omitempty"`
+	// +optional
+	AllowedDomains []string `json:"allowedDomains,omitempty"`
+	// +optional
+	AllowPublicUnauthenticatedAccess bool `json:"allowPublicUnauthenticatedAccess,omitempty"`
+	// +optional
+	AllowAnyAuthenticatedUser bool `json:"allowAnyAuthenticatedUser,omitempty"`
+	// +optional
+	PreserveHostHeader bool `json:"preserveHostHeader,omitempty"`
+	// +optional
+	SetRequestHeaders map[string]string `json:"setRequestHeaders,omitempty"`
+
+	// Options holds any further Pomerium policy options in their configuration file form, e.g. `timeout: 30s`.  The
+	// fields above take precedence over Options.
+	//
+	// Only the options governed by policy rules or matching requests are typed.  The rest change between Pomerium
+	// releases, so they are passed through untouched rather than pinning the CRD to one release; they are still
+	// validated when the route is accepted.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +optional
+	Options *runtime.RawExtension `json:"options,omitempty"`
+}
+
+// PomeriumRouteStatus reports whether a PomeriumRoute is part of the Pomerium configuration
+type PomeriumRouteStatus struct {
+	// ObservedGeneration is the generation of the spec last reconciled
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions hold the Accepted condition of the route
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="From",type=string,JSONPath=`.spec.from`
+// +kubebuilder:printcolumn:name="Accepted",type=string,JSONPath=`.status.conditions[?(@.type=="Accepted")].status`
+
+// PomeriumRoute is a route through Pomerium to one or more Services
+type PomeriumRoute struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PomeriumRouteSpec   `json:"spec,omitempty"`
+	Status PomeriumRouteStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// PomeriumRouteList contains a list of PomeriumRoute
+type PomeriumRouteList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PomeriumRoute `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PomeriumRoute{}, &PomeriumRouteList{})
+}
@@ -1,22 +1,33 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"io/ioutil"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/iancoleman/strcase"
+	"github.com/pomerium/pomerium-operator/api/v1alpha1"
 	"github.com/pomerium/pomerium-operator/internal/configmanager"
 	"github.com/pomerium/pomerium-operator/internal/controller"
 	"github.com/pomerium/pomerium-operator/internal/deploymentmanager"
 	"github.com/pomerium/pomerium-operator/internal/log"
 	"github.com/pomerium/pomerium-operator/internal/operator"
+	"github.com/pomerium/pomerium-operator/internal/webhook"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 	corev1 "k8s.io/api/core/v1"
 	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -24,6 +35,9 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/manager/signals"
 )
 
+// eventSource identifies Events recorded by the operator
+const eventSource = "pomerium-operator"
+
 var (
 	vcfg        *viper.Viper = viper.New()
 	logger                   = log.L
@@ -32,19 +46,34 @@ var (
 
 type cmdConfig struct {
 	BaseConfigFile    string
+	BaseConfig        []string
+	PomeriumConfig    string
 	Debug             bool
 	Election          bool
 	ElectionConfigMap string
 	ElectionNamespace string
 
-	IngressClass        string
-	MetricsAddress      string
-	HealthAddress       string
-	Namespace           string
-	PomeriumSecret      string
-	PomeriumNamespace   string
-	PomeriumDeployments []string
-	ServiceClass        string
+	IngressClass          string
+	ControllerName        string
+	GatewayControllerName string
+	PublishService        string
+	PolicyTemplates       string
+	PolicyRules           string
+	HostRules             string
+	PublishAddress        []string
+	ClusterDomain         string
+	AddressStrategy       string
+	MetricsAddress        string
+	HealthAddress         string
+	Namespace             []string
+	NamespaceSelector     string
+	PomeriumSecret        string
+	PomeriumNamespace     string
+	PomeriumDeployments   []string
+	ServiceClass          string
+	WebhookPort           int
+	WebhookCertDir        string
+	WebhookFailOpen       bool
 }
 
 var rootCmd = &cobra.Command{
@@ -87,17 +116,44 @@ var rootCmd = &cobra.Command{
 
 		deploymentManager := deploymentmanager.NewDeploymentManager(kClient, operatorCfg.PomeriumDeployments, operatorCfg.PomeriumNamespace)
 		configManager.OnSave(deploymentManager.UpdateDeployments)
+		configManager.OnConflict(controller.RecordConflicts(o.GetEventRecorderFor(eventSource)))
+
+		ingressResource, err := servedIngressKind(kcfg)
+		if err != nil {
+			return err
+		}
 
-		if err := ingressController(o, configManager); err != nil {
+		if err := ingressController(o, configManager, ingressResource); err != nil {
 			return err
 		}
 		if err := serviceController(o, configManager); err != nil {
 			return err
 		}
+		if err := routeController(o, configManager); err != nil {
+			return err
+		}
+		if err := httpRouteController(o, configManager); err != nil {
+			return err
+		}
+		if err := configController(o, configManager); err != nil {
+			return err
+		}
+		if err := registerWebhooks(o, configManager, ingressResource); err != nil {
+			return err
+		}
 
 		if err := o.Add(configManager); err != nil {
 			return err
 		}
+		if operatorCfg.PomeriumConfig == "" {
+			sources, err := baseConfigSources()
+			if err != nil {
+				return err
+			}
+			if err := o.Add(configmanager.NewBaseConfigWatcher(sources, configManager)); err != nil {
+				return err
+			}
+		}
 
 		if err := o.Start(signals.SetupSignalHandler()); err != nil {
 			logger.Error(err, "operator failed to start.  exiting")
@@ -114,20 +170,37 @@ func main() {
 	}
 }
 func init() {
+	utilruntime.Must(v1alpha1.AddToScheme(scheme.Scheme))
+
 	rootCmd.PersistentFlags().Bool("debug", false, "Run in debug mode")
-	rootCmd.PersistentFlags().StringP("namespace", "n", "", "Namespaces to monitor")
+	rootCmd.PersistentFlags().StringSliceP("namespace", "n", []string{}, "Namespaces to monitor.  Default all namespaces")
+	rootCmd.PersistentFlags().String("namespace-selector", "", "Label selector restricting monitored namespaces, e.g. pomerium.io/enabled=true")
 	rootCmd.PersistentFlags().String("pomerium-secret", "pomerium", "Name of pomerium Secret to maintain")
 	rootCmd.PersistentFlags().String("pomerium-namespace", "kube-system", "Namespace pomerium Secret to maintain")
 	rootCmd.PersistentFlags().String("base-config-file", "./pomerium-base.yaml", "Path to base configuration file")
+	rootCmd.PersistentFlags().StringSlice("base-config", []string{}, "Ordered base configuration sources, deep-merged with later sources taking precedence: file:<path>, configmap:<namespace>/<name>[/<key>] or secret:<namespace>/<name>[/<key>].  Overrides base-config-file")
+	rootCmd.PersistentFlags().String("pomerium-config", "", "Name of the PomeriumConfig holding the base configuration.  Replaces base-config-file")
 
 	rootCmd.PersistentFlags().StringP("service-class", "s", "pomerium", "kubernetes.io/service.class to monitor")
 	rootCmd.PersistentFlags().StringP("ingress-class", "i", "pomerium", "kubernetes.io/ingress.class to monitor")
+	rootCmd.PersistentFlags().String("publish-service", "", "Service (namespace/name) whose address is published into the status of handled Ingresses")
+	rootCmd.PersistentFlags().StringSlice("publish-address", []string{}, "Static IPs or hostnames published into the status of handled Ingresses.  Overrides publish-service")
+	rootCmd.PersistentFlags().String("policy-templates", "", "ConfigMap (namespace/name) holding policy templates used by the ingress.pomerium.io/template annotation")
+	rootCmd.PersistentFlags().String("policy-rules", "", "ConfigMap (namespace/name) holding rules restricting which policy options may be set in which namespaces")
+	rootCmd.PersistentFlags().String("host-rules", "", "ConfigMap (namespace/name) holding rules reserving hosts for namespaces")
+	rootCmd.PersistentFlags().String("cluster-domain", "cluster.local", "Cluster DNS domain used to form Service addresses")
+	rootCmd.PersistentFlags().String("address-strategy", "dns", "How upstream Services are addressed: dns or cluster-ip")
+	rootCmd.PersistentFlags().String("controller-name", "pomerium.io/ingress-controller", "IngressClass spec.controller to claim.  Empty disables IngressClass handling")
+	rootCmd.PersistentFlags().String("gateway-controller-name", "pomerium.io/gateway-controller", "GatewayClass spec.controllerName to claim for Gateway API HTTPRoutes.  Empty disables Gateway API handling")
 
 	rootCmd.PersistentFlags().Bool("election", false, "Enable leader election (for running multiple controller replicas)")
 	rootCmd.PersistentFlags().String("election-configmap", "operator-leader-pomerium", "Name of ConfigMap to use for leader election")
 	rootCmd.PersistentFlags().String("election-namespace", "kube-system", "Namespace to use for leader election")
 	rootCmd.PersistentFlags().String("metrics-address", "0", "Address for metrics listener.  Default disabled")
 	rootCmd.PersistentFlags().String("health-address", "0", "Address for health check endpoint.  Default disabled")
+	rootCmd.PersistentFlags().Int("webhook-port", 0, "Port for the validating admission webhook server.  Default disabled")
+	rootCmd.PersistentFlags().String("webhook-cert-dir", "", "Directory holding tls.crt and tls.key for the webhook server, e.g. a mounted kubernetes.io/tls Secret.  Default <temp-dir>/k8s-webhook-server/serving-certs")
+	rootCmd.PersistentFlags().Bool("webhook-fail-open", false, "Admit Ingresses and Services whose pomerium annotations cannot be validated, instead of rejecting them")
 	rootCmd.PersistentFlags().StringSlice("pomerium-deployments", []string{}, "List of Deployments in the pomerium-namespace to update when the [base-config-file] changes")
 
 	err := bindViper(vcfg, rootCmd.PersistentFlags())
@@ -155,36 +228,206 @@ func newRestClient(config *rest.Config) (client.Client, error) {
 }
 
 func newConfigManager(kClient client.Client) (cm *configmanager.ConfigManager, err error) {
-	baseConfigFile := operatorCfg.BaseConfigFile
 	cm = configmanager.NewConfigManager(operatorCfg.PomeriumNamespace, operatorCfg.PomeriumSecret, kClient, time.Second*10)
 
-	baseBytes, err := ioutil.ReadFile(baseConfigFile)
+	if operatorCfg.PomeriumConfig != "" {
+		logger.V(1).Info("using base config from pomerium config", "name", operatorCfg.PomeriumConfig)
+		cm.RequireBaseConfig()
+		return
+	}
+
+	sources, err := baseConfigSources()
 	if err != nil {
+		return cm, err
+	}
 
-		return cm, fmt.Errorf("failed to load base config file: %w", err)
+	layers, err := configmanager.LoadBaseConfigs(context.Background(), kClient, sources)
+	if err != nil {
+		return cm, fmt.Errorf("failed to load base config: %w", err)
 	}
 
-	if err := cm.SetBaseConfig(baseBytes); err != nil {
-		return cm, fmt.Errorf("failed to set base config from %s: %w", baseConfigFile, err)
+	if err := cm.SetBaseConfigs(layers); err != nil {
+		return cm, fmt.Errorf("failed to set base config from %v: %w", sources, err)
 	}
 	return
 }
 
-func ingressReconciler(cm *configmanager.ConfigManager) *controller.Reconciler {
-	ingressResource := &extensionsv1beta1.Ingress{}
-	return controller.NewReconciler(ingressResource, operatorCfg.IngressClass, cm)
+// baseConfigSources returns the ordered base config sources.  base-config-file is used if no base-config is set.
+func baseConfigSources() ([]configmanager.BaseConfigSource, error) {
+	if len(operatorCfg.BaseConfig) == 0 {
+		return []configmanager.BaseConfigSource{{Kind: configmanager.BaseConfigSourceFile, Path: operatorCfg.BaseConfigFile}}, nil
+	}
+
+	sources := make([]configmanager.BaseConfigSource, 0, len(operatorCfg.BaseConfig))
+	for _, value := range operatorCfg.BaseConfig {
+		source, err := configmanager.ParseBaseConfigSource(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base-config: %w", err)
+		}
+		sources = append(sources, source)
+	}
+	return sources, nil
+}
+
+// setAddressing configures how reconciler forms upstream Service addresses
+func setAddressing(reconciler *controller.Reconciler) error {
+	if operatorCfg.AddressStrategy != "" {
+		strategy, err := controller.ParseAddressStrategy(operatorCfg.AddressStrategy)
+		if err != nil {
+			return fmt.Errorf("invalid address-strategy: %w", err)
+		}
+		reconciler.SetAddressStrategy(strategy)
+	}
+	reconciler.SetClusterDomain(operatorCfg.ClusterDomain)
+	return nil
+}
+
+// setNamespaces restricts reconciler to the monitored namespaces
+func setNamespaces(reconciler *controller.Reconciler) error {
+	reconciler.SetNamespaces(operatorCfg.Namespace)
+	if operatorCfg.NamespaceSelector != "" {
+		selector, err := labels.Parse(operatorCfg.NamespaceSelector)
+		if err != nil {
+			return fmt.Errorf("invalid namespace-selector: %w", err)
+		}
+		reconciler.SetNamespaceSelector(selector)
+	}
+	return nil
+}
+
+// setPolicyTemplates sets the policy templates ConfigMap of reconciler
+func setPolicyTemplates(reconciler *controller.Reconciler) error {
+	if operatorCfg.PolicyTemplates == "" {
+		return nil
+	}
+
+	policyTemplates, err := parseNamespacedName(operatorCfg.PolicyTemplates)
+	if err != nil {
+		return fmt.Errorf("invalid policy-templates: %w", err)
+	}
+	reconciler.SetPolicyTemplates(policyTemplates)
+	return nil
+}
+
+// setPolicyRules sets the policy rules and host rules governing what reconciler routes
+func setPolicyRules(reconciler *controller.Reconciler) error {
+	if operatorCfg.PolicyRules != "" {
+		policyRules, err := parseNamespacedName(operatorCfg.PolicyRules)
+		if err != nil {
+			return fmt.Errorf("invalid policy-rules: %w", err)
+		}
+		reconciler.SetPolicyRules(policyRules)
+	}
+
+	if operatorCfg.HostRules != "" {
+		hostRules, err := parseNamespacedName(operatorCfg.HostRules)
+		if err != nil {
+			return fmt.Errorf("invalid host-rules: %w", err)
+		}
+		reconciler.SetHostRules(hostRules)
+	}
+	return nil
+}
+
+// configMapWatches returns the watches needed for reconciler to re-render routes when policy templates, policy rules or
+// host rules change
+func configMapWatches(reconciler *controller.Reconciler) []operator.Watch {
+	if operatorCfg.PolicyTemplates == "" && operatorCfg.PolicyRules == "" && operatorCfg.HostRules == "" {
+		return nil
+	}
+	return []operator.Watch{{Object: &corev1.ConfigMap{}, Mapper: reconciler.RequestsForConfigMap}}
+}
+
+// namespaceWatches returns the watches needed for reconciler to follow namespace policy defaults and namespaces joining
+// or leaving the namespace selector
+func namespaceWatches(reconciler *controller.Reconciler) []operator.Watch {
+	return []operator.Watch{{Object: &corev1.Namespace{}, Mapper: reconciler.RequestsForNamespace}}
+}
+
+// ingressClassKind returns the IngressClass type matching ingressResource, or nil if the API server serves none.
+// IngressClass is served alongside networking.k8s.io/v1 Ingress, and as networking.k8s.io/v1beta1 on 1.18 clusters
+// serving extensions/v1beta1 Ingress.
+func ingressClassKind(o *operator.Operator, ingressResource client.Object) client.Object {
+	if _, ok := ingressResource.(*networkingv1.Ingress); ok {
+		return &networkingv1.IngressClass{}
+	}
+	if ingressClass := (&networkingv1beta1.IngressClass{}); o.Serves(ingressClass) {
+		return ingressClass
+	}
+	return nil
 }
 
-func serviceReconciler(cm *configmanager.ConfigManager) *controller.Reconciler {
+func ingressReconciler(o *operator.Operator, cm *configmanager.ConfigManager, ingressResource client.Object) (*controller.Reconciler, error) {
+	reconciler := controller.NewReconciler(ingressResource, operatorCfg.IngressClass, cm)
+	if err := setAddressing(reconciler); err != nil {
+		return nil, err
+	}
+	if err := setNamespaces(reconciler); err != nil {
+		return nil, err
+	}
+	if err := setPolicyTemplates(reconciler); err != nil {
+		return nil, err
+	}
+	if err := setPolicyRules(reconciler); err != nil {
+		return nil, err
+	}
+
+	if operatorCfg.PublishService != "" {
+		publishService, err := parseNamespacedName(operatorCfg.PublishService)
+		if err != nil {
+			return nil, fmt.Errorf("invalid publish-service: %w", err)
+		}
+		reconciler.SetPublishService(publishService)
+	}
+	reconciler.SetPublishAddresses(operatorCfg.PublishAddress)
+
+	if ingressClassKind(o, ingressResource) != nil && operatorCfg.ControllerName != "" {
+		reconciler.SetIngressController(operatorCfg.ControllerName)
+	}
+
+	return reconciler, nil
+}
+
+func serviceReconciler(cm *configmanager.ConfigManager) (*controller.Reconciler, error) {
 	serviceResource := &corev1.Service{}
-	return controller.NewReconciler(serviceResource, operatorCfg.ServiceClass, cm)
+	reconciler := controller.NewReconciler(serviceResource, operatorCfg.ServiceClass, cm)
+	if err := setAddressing(reconciler); err != nil {
+		return nil, err
+	}
+	if err := setNamespaces(reconciler); err != nil {
+		return nil, err
+	}
+	if err := setPolicyTemplates(reconciler); err != nil {
+		return nil, err
+	}
+	if err := setPolicyRules(reconciler); err != nil {
+		return nil, err
+	}
+	return reconciler, nil
 }
 
-func ingressController(o *operator.Operator, cm *configmanager.ConfigManager) (err error) {
-	ingressResource := &extensionsv1beta1.Ingress{}
-	reconciler := ingressReconciler(cm)
+func ingressController(o *operator.Operator, cm *configmanager.ConfigManager, ingressResource client.Object) (err error) {
+	reconciler, err := ingressReconciler(o, cm, ingressResource)
+	if err != nil {
+		return err
+	}
+	reconciler.SetEventRecorder(o.GetEventRecorderFor(eventSource))
+
+	if err := o.IndexField(ingressResource, controller.BackendServiceIndex, controller.IndexBackendServices); err != nil {
+		return fmt.Errorf("could not index ingress backend services: %w", err)
+	}
+
+	watches := []operator.Watch{
+		{Object: &corev1.Secret{}, Mapper: reconciler.RequestsForSecret},
+		{Object: &corev1.Service{}, Mapper: reconciler.RequestsForService},
+	}
+	watches = append(watches, namespaceWatches(reconciler)...)
+	watches = append(watches, configMapWatches(reconciler)...)
+	if ingressClass := ingressClassKind(o, ingressResource); ingressClass != nil && operatorCfg.ControllerName != "" {
+		watches = append(watches, operator.Watch{Object: ingressClass, Mapper: reconciler.RequestsForIngressClass})
+	}
 
-	if err := o.CreateController(reconciler, "pomerium-ingress", ingressResource); err != nil {
+	if err := o.CreateController(reconciler, "pomerium-ingress", ingressResource, watches...); err != nil {
 		return fmt.Errorf("could not register ingress controller: %w", err)
 	}
 
@@ -193,9 +436,17 @@ func ingressController(o *operator.Operator, cm *configmanager.ConfigManager) (e
 
 func serviceController(o *operator.Operator, cm *configmanager.ConfigManager) (err error) {
 	serviceResource := &corev1.Service{}
-	reconciler := serviceReconciler(cm)
+	reconciler, err := serviceReconciler(cm)
+	if err != nil {
+		return err
+	}
+	reconciler.SetEventRecorder(o.GetEventRecorderFor(eventSource))
+
+	watches := []operator.Watch{{Object: &corev1.Secret{}, Mapper: reconciler.RequestsForSecret}}
+	watches = append(watches, namespaceWatches(reconciler)...)
+	watches = append(watches, configMapWatches(reconciler)...)
 
-	if err := o.CreateController(reconciler, "pomerium-service", serviceResource); err != nil {
+	if err := o.CreateController(reconciler, "pomerium-service", serviceResource, watches...); err != nil {
 		return fmt.Errorf("could not register service controller: %w", err)
 
 	}
@@ -203,11 +454,175 @@ func serviceController(o *operator.Operator, cm *configmanager.ConfigManager) (e
 	return nil
 }
 
+// routeController registers the PomeriumRoute controller if the CRD is installed
+func routeController(o *operator.Operator, cm *configmanager.ConfigManager) error {
+	routeResource := &v1alpha1.PomeriumRoute{}
+	if !o.Serves(routeResource) {
+		logger.Info("PomeriumRoute CRD is not installed.  PomeriumRoutes will be ignored")
+		return nil
+	}
+
+	reconciler := controller.NewRouteReconciler(cm)
+	if err := setAddressing(reconciler.Reconciler); err != nil {
+		return err
+	}
+	if err := setNamespaces(reconciler.Reconciler); err != nil {
+		return err
+	}
+	if err := setPolicyRules(reconciler.Reconciler); err != nil {
+		return err
+	}
+	reconciler.SetEventRecorder(o.GetEventRecorderFor(eventSource))
+
+	watches := []operator.Watch{
+		{Object: &corev1.Service{}, Mapper: reconciler.RequestsForService},
+		{Object: &corev1.Namespace{}, Mapper: reconciler.RequestsForNamespace},
+	}
+	if operatorCfg.PolicyRules != "" || operatorCfg.HostRules != "" {
+		watches = append(watches, operator.Watch{Object: &corev1.ConfigMap{}, Mapper: reconciler.RequestsForConfigMap})
+	}
+
+	if err := o.CreateController(reconciler, "pomerium-route", routeResource, watches...); err != nil {
+		return fmt.Errorf("could not register route controller: %w", err)
+	}
+
+	return nil
+}
+
+// httpRouteController registers the Gateway API HTTPRoute controller if a gateway-controller-name is set and the
+// Gateway API CRDs are installed
+func httpRouteController(o *operator.Operator, cm *configmanager.ConfigManager) error {
+	if operatorCfg.GatewayControllerName == "" {
+		return nil
+	}
+
+	version := ""
+	for _, v := range controller.GatewayVersions {
+		if o.Serves(controller.NewGatewayObject(v, "HTTPRoute")) {
+			version = v
+			break
+		}
+	}
+	if version == "" {
+		logger.Info("Gateway API CRDs are not installed.  HTTPRoutes will be ignored")
+		return nil
+	}
+
+	reconciler := controller.NewHTTPRouteReconciler(version, operatorCfg.GatewayControllerName, cm)
+	if err := setAddressing(reconciler.Reconciler); err != nil {
+		return err
+	}
+	if err := setNamespaces(reconciler.Reconciler); err != nil {
+		return err
+	}
+	if err := setPolicyRules(reconciler.Reconciler); err != nil {
+		return err
+	}
+	reconciler.SetEventRecorder(o.GetEventRecorderFor(eventSource))
+
+	watches := []operator.Watch{
+		{Object: controller.NewGatewayObject(version, "Gateway"), Mapper: reconciler.RequestsForGateway},
+		{Object: controller.NewGatewayObject(version, "GatewayClass"), Mapper: reconciler.RequestsForGatewayClass},
+		{Object: &corev1.Service{}, Mapper: reconciler.RequestsForService},
+		{Object: &corev1.Namespace{}, Mapper: reconciler.RequestsForNamespace},
+	}
+	if operatorCfg.PolicyRules != "" || operatorCfg.HostRules != "" {
+		watches = append(watches, operator.Watch{Object: &corev1.ConfigMap{}, Mapper: reconciler.RequestsForConfigMap})
+	}
+
+	if err := o.CreateController(reconciler, "pomerium-httproute", controller.NewGatewayObject(version, "HTTPRoute"), watches...); err != nil {
+		return fmt.Errorf("could not register http route controller: %w", err)
+	}
+
+	return nil
+}
+
+// configController registers the PomeriumConfig controller if a pomerium-config is set.  The CRD must be installed.
+func configController(o *operator.Operator, cm *configmanager.ConfigManager) error {
+	if operatorCfg.PomeriumConfig == "" {
+		return nil
+	}
+
+	configResource := &v1alpha1.PomeriumConfig{}
+	if !o.Serves(configResource) {
+		return fmt.Errorf("pomerium-config is set but the PomeriumConfig CRD is not installed")
+	}
+
+	reconciler := controller.NewConfigReconciler(operatorCfg.PomeriumConfig, cm)
+	reconciler.SetEventRecorder(o.GetEventRecorderFor(eventSource))
+	cm.OnSave(reconciler.RecordSave)
+
+	if err := o.CreateController(reconciler, "pomerium-config", configResource); err != nil {
+		return fmt.Errorf("could not register pomerium config controller: %w", err)
+	}
+
+	return nil
+}
+
+// registerWebhooks serves the validating admission webhooks if a webhook-port is set
+func registerWebhooks(o *operator.Operator, cm *configmanager.ConfigManager, ingressResource client.Object) error {
+	if operatorCfg.WebhookPort == 0 {
+		return nil
+	}
+	o.RegisterWebhook(webhook.ConfigValidatorPath, webhook.NewConfigWebhook())
+
+	ingressValidator, err := ingressReconciler(o, cm, ingressResource)
+	if err != nil {
+		return err
+	}
+	newIngress := func() client.Object { return ingressResource.DeepCopyObject().(client.Object) }
+	o.RegisterWebhook(webhook.IngressValidatorPath, webhook.NewPolicyWebhook(ingressValidator, newIngress, operatorCfg.WebhookFailOpen))
+
+	serviceValidator, err := serviceReconciler(cm)
+	if err != nil {
+		return err
+	}
+	newService := func() client.Object { return &corev1.Service{} }
+	o.RegisterWebhook(webhook.ServiceValidatorPath, webhook.NewPolicyWebhook(serviceValidator, newService, operatorCfg.WebhookFailOpen))
+
+	return nil
+}
+
+// servedIngressKind returns the newest Ingress type served by the API server.  networking.k8s.io/v1 is preferred,
+// falling back to extensions/v1beta1 on clusters older than 1.19.
+func servedIngressKind(kcfg *rest.Config) (client.Object, error) {
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(kcfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create discovery client: %w", err)
+	}
+
+	resources, err := discoveryClient.ServerResourcesForGroupVersion(networkingv1.SchemeGroupVersion.String())
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to discover ingress api version: %w", err)
+	}
+
+	if resources != nil {
+		for _, resource := range resources.APIResources {
+			if resource.Name == "ingresses" {
+				logger.V(1).Info("using ingress api", "version", networkingv1.SchemeGroupVersion.String())
+				return &networkingv1.Ingress{}, nil
+			}
+		}
+	}
+
+	logger.V(1).Info("using ingress api", "version", extensionsv1beta1.SchemeGroupVersion.String())
+	return &extensionsv1beta1.Ingress{}, nil
+}
+
+// parseNamespacedName parses a `namespace/name` string
+func parseNamespacedName(value string) (types.NamespacedName, error) {
+	parts := strings.SplitN(value, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return types.NamespacedName{}, fmt.Errorf("%q is not in namespace/name form", value)
+	}
+	return types.NamespacedName{Namespace: parts[0], Name: parts[1]}, nil
+}
+
 func createOperator(kcfg *rest.Config) (*operator.Operator, error) {
 	o, err := operator.NewOperator(
 		operator.Options{
 			KubeConfig:              kcfg,
-			NameSpace:               operatorCfg.Namespace,
+			Namespaces:              operatorCfg.Namespace,
 			ServiceClass:            operatorCfg.ServiceClass,
 			IngressClass:            operatorCfg.IngressClass,
 			MetricsBindAddress:      operatorCfg.MetricsAddress,
@@ -215,6 +630,8 @@ func createOperator(kcfg *rest.Config) (*operator.Operator, error) {
 			LeaderElection:          operatorCfg.Election,
 			LeaderElectionID:        operatorCfg.ElectionConfigMap,
 			LeaderElectionNamespace: operatorCfg.ElectionNamespace,
+			WebhookPort:             operatorCfg.WebhookPort,
+			WebhookCertDir:          operatorCfg.WebhookCertDir,
 		},
 	)
 	return o, err
@@ -5,10 +5,12 @@ import (
 	"os"
 	"testing"
 
+	"github.com/pomerium/pomerium-operator/internal/configmanager"
 	"github.com/pomerium/pomerium-operator/internal/deploymentmanager"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
@@ -43,7 +45,11 @@ func Test_main_setup(t *testing.T) {
 	err = serviceController(o, cm)
 	assert.NoError(t, err, "could not create service controller")
 
-	err = ingressController(o, cm)
+	ingressResource, err := servedIngressKind(testCfg)
+	assert.NoError(t, err, "could not discover ingress api")
+	assert.NotNil(t, ingressResource)
+
+	err = ingressController(o, cm, ingressResource)
 	assert.NoError(t, err, "could not create ingress controller")
 
 }
@@ -136,6 +142,43 @@ func Test_newConfigManager(t *testing.T) {
 
 }
 
+func Test_newConfigManager_pomeriumConfig(t *testing.T) {
+	operatorCfg.PomeriumConfig = "pomerium"
+	operatorCfg.BaseConfigFile = "/nonexistent/pomerium-base.yaml"
+	defer func() { operatorCfg.PomeriumConfig = "" }()
+
+	kClient := fake.NewFakeClient()
+	cm, err := newConfigManager(kClient)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.NoError(t, cm.Save())
+	_, err = cm.GetPersistedConfig()
+	assert.Error(t, err, "config should not be saved before the pomerium config is loaded")
+}
+
+func Test_baseConfigSources(t *testing.T) {
+	defer func() { operatorCfg.BaseConfig = nil }()
+
+	operatorCfg.BaseConfigFile = "/etc/pomerium/pomerium-base.yaml"
+	sources, err := baseConfigSources()
+	assert.NoError(t, err)
+	assert.Equal(t, []configmanager.BaseConfigSource{{Kind: configmanager.BaseConfigSourceFile, Path: "/etc/pomerium/pomerium-base.yaml"}}, sources)
+
+	operatorCfg.BaseConfig = []string{"file:/etc/pomerium/pomerium-base.yaml", "secret:pomerium/pomerium-secrets"}
+	sources, err = baseConfigSources()
+	assert.NoError(t, err)
+	assert.Equal(t, []configmanager.BaseConfigSource{
+		{Kind: configmanager.BaseConfigSourceFile, Path: "/etc/pomerium/pomerium-base.yaml"},
+		{Kind: configmanager.BaseConfigSourceSecret, NamespacedName: types.NamespacedName{Namespace: "pomerium", Name: "pomerium-secrets"}},
+	}, sources)
+
+	operatorCfg.BaseConfig = []string{"secret:pomerium-secrets"}
+	_, err = baseConfigSources()
+	assert.Error(t, err)
+}
+
 func Test_getConfig(t *testing.T) {
 
 	kcfgFile, err := ioutil.TempFile("", "pomerium-operator_test-kube-config.yaml")
@@ -209,3 +252,24 @@ func Test_bindViper(t *testing.T) {
 	}
 
 }
+
+func Test_parseNamespacedName(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    types.NamespacedName
+		wantErr bool
+	}{
+		{value: "pomerium/pomerium-proxy", want: types.NamespacedName{Namespace: "pomerium", Name: "pomerium-proxy"}},
+		{value: "pomerium-proxy", wantErr: true},
+		{value: "/pomerium-proxy", wantErr: true},
+		{value: "pomerium/", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseNamespacedName(tt.value)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
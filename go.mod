@@ -3,6 +3,7 @@ module github.com/pomerium/pomerium-operator
 go 1.14
 
 require (
+	github.com/fsnotify/fsnotify v1.5.1
 	github.com/ghodss/yaml v1.0.0
 	github.com/go-logr/logr v1.1.0
 	github.com/go-logr/zapr v1.1.0
@@ -11,6 +12,7 @@ require (
 	github.com/googleapis/gnostic v0.5.4 // indirect
 	github.com/iancoleman/strcase v0.2.0
 	github.com/pomerium/pomerium v0.16.4
+	github.com/prometheus/client_golang v1.11.0
 	github.com/spf13/cobra v1.2.1
 	github.com/spf13/pflag v1.0.5
 	github.com/spf13/viper v1.10.1
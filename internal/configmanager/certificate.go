@@ -0,0 +1,18 @@
+package configmanager
+
+// Certificate is a PEM encoded certificate and private key pair to be served by Pomerium
+type Certificate struct {
+	Cert []byte
+	Key  []byte
+}
+
+// certificateOptions is the serialized form of the pomerium `certificates` option
+type certificateOptions struct {
+	Certificates []certificateOption `yaml:"certificates"`
+}
+
+// certificateOption is a single base64 encoded entry of the pomerium `certificates` option
+type certificateOption struct {
+	Cert string `yaml:"cert"`
+	Key  string `yaml:"key"`
+}
@@ -2,7 +2,9 @@ package configmanager
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
+	"reflect"
 	"sort"
 	"sync"
 	"time"
@@ -23,19 +25,25 @@ const configKey = "config.yaml"
 
 // ConfigManager tracks policy groups related to a given ResourceIdentifier and handles update to a Pomerium config Secret via the API server
 //
-// ConfigManager accepts a baseConfig which will be merged into the persisted configuration
+// ConfigManager accepts layers of base configuration which will be merged into the persisted configuration
 //
 // Configuration can be persisted at intervals or on-demand.  Set() and Remove() operations are stored in memory only until a Save() or Start() loop
 // persist the configuration.
 type ConfigManager struct {
-	namespace    string
-	secret       string
-	client       client.Client
-	mutex        sync.RWMutex
-	policyList   map[ResourceIdentifier][]pomeriumconfig.Policy
-	baseConfig   []byte
-	settleTicker *time.Ticker
-	onSaves      []ConfigReceiver
+	namespace          string
+	secret             string
+	client             client.Client
+	mutex              sync.RWMutex
+	policyList         map[ResourceIdentifier][]pomeriumconfig.Policy
+	metaList           map[ResourceIdentifier]RouteMeta
+	certList           map[ResourceIdentifier][]Certificate
+	baseConfigs        [][]byte
+	baseConfigRequired bool
+	settleTicker       *time.Ticker
+	onSaves            []ConfigReceiver
+	onConflicts        []ConflictReceiver
+	conflictMutex      sync.Mutex
+	conflicts          map[Conflict]bool
 }
 
 // NewConfigManager returns a ConfigManager which uses client to update secret in namespace at settlePeriod interval if
@@ -46,34 +54,67 @@ func NewConfigManager(namespace string, secret string, client client.Client, set
 		secret:       secret,
 		client:       client,
 		policyList:   make(map[ResourceIdentifier][]pomeriumconfig.Policy),
+		metaList:     make(map[ResourceIdentifier]RouteMeta),
+		certList:     make(map[ResourceIdentifier][]Certificate),
 		settleTicker: time.NewTicker(settlePeriod),
 	}
 }
 
 // Set Adds or replaces the list of policies associated with a given ResourceIdentifier id
 func (c *ConfigManager) Set(id ResourceIdentifier, policy []pomeriumconfig.Policy) {
+	c.SetWithMeta(id, policy, RouteMeta{})
+}
+
+// SetWithMeta Adds or replaces the list of policies associated with a given ResourceIdentifier id.  meta decides which
+// resource keeps a route claimed by several resources.  It reports whether the policies of id changed.
+func (c *ConfigManager) SetWithMeta(id ResourceIdentifier, policy []pomeriumconfig.Policy, meta RouteMeta) bool {
 	logger.V(1).Info("setting policy for resource", "id", id)
 
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
+	previous, ok := c.policyList[id]
 	c.policyList[id] = policy
+	c.metaList[id] = meta
+	if ok && reflect.DeepEqual(previous, policy) {
+		logger.V(1).Info("policy for resource unchanged", "id", id)
+		return false
+	}
 	logger.Info("set policy for resource", "id", id)
+	return true
+}
+
+// SetCertificates Adds or replaces the list of certificates associated with a given ResourceIdentifier id.  An empty list
+// removes any certificates currently associated with id.
+func (c *ConfigManager) SetCertificates(id ResourceIdentifier, certs []Certificate) {
+	logger.V(1).Info("setting certificates for resource", "id", id)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if len(certs) == 0 {
+		delete(c.certList, id)
+		return
+	}
+	c.certList[id] = certs
 }
 
-// Remove Deletes the list of policies associated with a given ResourceIdentifier id
+// Remove Deletes the list of policies and certificates associated with a given ResourceIdentifier id
 func (c *ConfigManager) Remove(id ResourceIdentifier) error {
 	logger.V(1).Info("removing policy for resource", "id", id)
 
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
+	delete(c.certList, id)
+
 	if _, ok := c.policyList[id]; !ok {
 		logger.V(1).Info("resource not found", "id", id)
 		return nil
 	}
 
 	delete(c.policyList, id)
+	delete(c.metaList, id)
 	logger.Info("removed policy for resource", "id", id)
 	return nil
 }
@@ -82,12 +123,19 @@ func (c *ConfigManager) Remove(id ResourceIdentifier) error {
 func (c *ConfigManager) Save() error {
 	logger.V(1).Info("updating config Secret")
 
-	var tmpOptions pomeriumconfig.Options
+	c.mutex.RLock()
+	waiting := c.baseConfigRequired && c.baseConfigs == nil
+	c.mutex.RUnlock()
+	if waiting {
+		logger.Info("waiting for base config before saving")
+		return nil
+	}
 
-	tmpOptions, err := c.GetCurrentConfig()
+	tmpOptions, conflicts, err := c.currentConfig()
 	if err != nil {
 		return fmt.Errorf("could not render current config: %w", err)
 	}
+	c.reportConflicts(conflicts)
 
 	secretObj := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: c.secret, Namespace: c.namespace}}
 	op, err := controllerutil.CreateOrUpdate(context.TODO(), c.client, secretObj, func() error {
@@ -116,16 +164,58 @@ func (c *ConfigManager) Save() error {
 // SetBaseConfig Allows arbitrary Pomerium configuration to be set with the resource based policies being saved.  This allows the user to
 // still set all Pomerium options in a config file, even though it is being managed by ConfigManager.
 func (c *ConfigManager) SetBaseConfig(configBytes []byte) error {
-	err := yaml.Unmarshal(configBytes, &pomeriumconfig.Options{})
+	return c.SetBaseConfigs([][]byte{configBytes})
+}
+
+// SetBaseConfigs replaces the base configuration with layers, which are deep-merged in order so later layers override
+// options set by earlier ones.  This allows secret options to be kept apart from the rest of the configuration.
+//
+// The base configuration is left unchanged if the merged layers are not a valid configuration.
+func (c *ConfigManager) SetBaseConfigs(layers [][]byte) error {
+	merged, err := mergeBaseConfigs(layers)
 	if err != nil {
+		return err
+	}
+	if err := ValidateBaseConfig(merged); err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.baseConfigs = layers
+	return nil
+}
+
+// RequireBaseConfig defers saving until a base configuration has been set with SetBaseConfig, so a base configuration
+// loaded after startup is not briefly replaced by resource policies alone
+func (c *ConfigManager) RequireBaseConfig() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.baseConfigRequired = true
+}
+
+// ValidateBaseConfig checks that configBytes can be used as a base configuration.  Options not set by configBytes take
+// Pomerium's defaults, so a partial configuration is valid.
+func ValidateBaseConfig(configBytes []byte) error {
+	options := pomeriumconfig.NewDefaultOptions()
+	if err := yaml.Unmarshal(configBytes, options); err != nil {
 		return fmt.Errorf("failed to unmarshal configuration: %w", err)
 	}
-	c.baseConfig = configBytes
+	if err := options.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
 	return nil
 }
 
 func (c *ConfigManager) getBaseConfig() (options pomeriumconfig.Options, err error) {
-	err = yaml.Unmarshal(c.baseConfig, &options)
+	merged, err := mergeBaseConfigs(c.baseConfigs)
+	if err != nil {
+		return options, fmt.Errorf("failed to merge base config: %w", err)
+	}
+
+	err = yaml.Unmarshal(merged, &options)
 	if err != nil {
 		return options, fmt.Errorf("failed to load base config: %w", err)
 	}
@@ -133,7 +223,16 @@ func (c *ConfigManager) getBaseConfig() (options pomeriumconfig.Options, err err
 }
 
 // GetCurrentConfig retrieves the current in-memory configuration from ConfigManager
+//
+// Where several resources claim the same route, only the policy of the resource winning the conflict is included.  See
+// RouteMeta.
 func (c *ConfigManager) GetCurrentConfig() (options pomeriumconfig.Options, err error) {
+	options, _, err = c.currentConfig()
+	return
+}
+
+// currentConfig returns the current in-memory configuration and the conflicts resolved in forming it
+func (c *ConfigManager) currentConfig() (options pomeriumconfig.Options, conflicts []Conflict, err error) {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
 
@@ -141,7 +240,16 @@ func (c *ConfigManager) GetCurrentConfig() (options pomeriumconfig.Options, err
 	options, err = c.getBaseConfig()
 	if err != nil {
 		logger.Error(err, "could not load base configuration")
-		return options, fmt.Errorf("could not load base configuration: %w", err)
+		return options, nil, fmt.Errorf("could not load base configuration: %w", err)
+	}
+
+	conflicts = c.resolveConflicts()
+	lost := make(map[ResourceIdentifier]map[string]bool)
+	for _, conflict := range conflicts {
+		if lost[conflict.Loser] == nil {
+			lost[conflict.Loser] = make(map[string]bool)
+		}
+		lost[conflict.Loser][conflict.Route] = true
 	}
 
 	// Sort identifiers to return a consistent policy
@@ -149,25 +257,88 @@ func (c *ConfigManager) GetCurrentConfig() (options pomeriumconfig.Options, err
 	for id := range c.policyList {
 		policyIds = append(policyIds, id)
 	}
+	sortResourceIdentifiers(policyIds)
+
+	// Append policies in order, skipping routes lost to another resource
+	for _, id := range policyIds {
+		for _, policy := range c.policyList[id] {
+			if lost[id][routeKey(policy)] {
+				continue
+			}
+			options.Policies = append(options.Policies, policy)
+		}
+	}
+
+	if err = c.appendCertificates(&options); err != nil {
+		logger.Error(err, "could not add resource certificates")
+		return options, nil, fmt.Errorf("could not add resource certificates: %w", err)
+	}
+
+	return
+}
+
+// appendCertificates adds the certificates of all resources to the certificates already present in options
+func (c *ConfigManager) appendCertificates(options *pomeriumconfig.Options) error {
+	if len(c.certList) == 0 {
+		return nil
+	}
+
+	var certIds []ResourceIdentifier
+	for id := range c.certList {
+		certIds = append(certIds, id)
+	}
+	sortResourceIdentifiers(certIds)
+
+	optionBytes, err := yaml.Marshal(options)
+	if err != nil {
+		return fmt.Errorf("could not serialize options: %w", err)
+	}
+
+	var certOptions certificateOptions
+	if err := yaml.Unmarshal(optionBytes, &certOptions); err != nil {
+		return fmt.Errorf("could not read certificates from options: %w", err)
+	}
+
+	seen := make(map[certificateOption]bool)
+	for _, cert := range certOptions.Certificates {
+		seen[cert] = true
+	}
 
-	sort.Slice(policyIds, func(i, j int) bool {
+	for _, id := range certIds {
+		for _, cert := range c.certList[id] {
+			certOption := certificateOption{
+				Cert: base64.StdEncoding.EncodeToString(cert.Cert),
+				Key:  base64.StdEncoding.EncodeToString(cert.Key),
+			}
+			if seen[certOption] {
+				continue
+			}
+			seen[certOption] = true
+			certOptions.Certificates = append(certOptions.Certificates, certOption)
+		}
+	}
+
+	certBytes, err := yaml.Marshal(certOptions)
+	if err != nil {
+		return fmt.Errorf("could not serialize certificates: %w", err)
+	}
+
+	return yaml.Unmarshal(certBytes, options)
+}
+
+// sortResourceIdentifiers orders ids by namespaced name and then GVK
+func sortResourceIdentifiers(ids []ResourceIdentifier) {
+	sort.Slice(ids, func(i, j int) bool {
 
 		switch {
-		case policyIds[i].NamespacedName.String() < policyIds[j].NamespacedName.String():
+		case ids[i].NamespacedName.String() < ids[j].NamespacedName.String():
 			return true
-		case policyIds[i].NamespacedName.String() > policyIds[j].NamespacedName.String():
+		case ids[i].NamespacedName.String() > ids[j].NamespacedName.String():
 			return false
 
 		}
-		return policyIds[i].GVK.String() < policyIds[j].GVK.String()
+		return ids[i].GVK.String() < ids[j].GVK.String()
 	})
-
-	// Append policies in order
-	for _, id := range policyIds {
-		options.Policies = append(options.Policies, c.policyList[id]...)
-	}
-
-	return
 }
 
 // GetPersistedConfig retrieves the currently persisted config from the API server
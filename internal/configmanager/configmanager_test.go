@@ -69,13 +69,33 @@ func Test_getBaseConfig(t *testing.T) {
 	opt, err := cm.getBaseConfig()
 
 	assert.Empty(t, cmp.Diff(
-		cm.baseConfig,
-		mockBaseConfigBytes(t),
+		cm.baseConfigs,
+		[][]byte{mockBaseConfigBytes(t)},
 	))
 	assert.Empty(t, cmp.Diff(opt, mockBaseConfig, cmpopts.IgnoreUnexported(pomeriumconfig.Options{})))
 	assert.NoError(t, err)
 }
 
+func Test_SetBaseConfigs(t *testing.T) {
+	cm := NewConfigManager("test", "pomerium", newMockClient(t), time.Nanosecond*1)
+
+	base := []byte("insecure_server: true\nforward_auth_url: https://nginx-hates-you.beyondcorp.org\nshared_secret: placeholder")
+	secrets := []byte("shared_secret: c2VjcmV0\ncookie_secret: Y29va2ll")
+	assert.NoError(t, cm.SetBaseConfigs([][]byte{base, secrets}))
+
+	opt, err := cm.getBaseConfig()
+	assert.NoError(t, err)
+	assert.True(t, opt.InsecureServer)
+	assert.Equal(t, "https://nginx-hates-you.beyondcorp.org", opt.ForwardAuthURLString)
+	optBytes, err := yaml.Marshal(opt)
+	assert.NoError(t, err)
+	assert.Contains(t, string(optBytes), "shared_secret: c2VjcmV0")
+	assert.Contains(t, string(optBytes), "cookie_secret: Y29va2ll")
+
+	assert.Error(t, cm.SetBaseConfigs([][]byte{base, []byte("insecure_server: not a bool")}))
+	assert.Equal(t, [][]byte{base, secrets}, cm.baseConfigs, "invalid layers must not replace the base config")
+}
+
 func Test_Save(t *testing.T) {
 
 	policyList := map[ResourceIdentifier][]pomeriumconfig.Policy{
@@ -230,11 +250,47 @@ func Test_Save_unmarshalError(t *testing.T) {
 
 	assert.Error(t, cm.SetBaseConfig(garbage))
 
-	cm.baseConfig = garbage
+	cm.baseConfigs = [][]byte{garbage}
 	assert.Error(t, cm.Save())
 
 }
 
+func Test_Save_requireBaseConfig(t *testing.T) {
+	cm := NewConfigManager("test", "pomerium", fake.NewFakeClient(), time.Nanosecond*1)
+	cm.RequireBaseConfig()
+	cm.Set(newIngressResourceIdentifier("test"), []pomeriumconfig.Policy{{To: "foo", From: "bar"}})
+
+	assert.NoError(t, cm.Save())
+	_, err := cm.GetPersistedConfig()
+	assert.Error(t, err, "config must not be saved without a base config")
+
+	assert.NoError(t, cm.SetBaseConfig(mockBaseConfigBytes(t)))
+	assert.NoError(t, cm.Save())
+	persisted, err := cm.GetPersistedConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, mockBaseConfig.ForwardAuthURLString, persisted.ForwardAuthURLString)
+	assert.Len(t, persisted.Policies, 1)
+}
+
+func Test_SetWithMeta_changed(t *testing.T) {
+	cm := NewConfigManager("test", "pomerium", fake.NewFakeClient(), time.Nanosecond*1)
+	id := newIngressResourceIdentifier("test")
+
+	assert.True(t, cm.SetWithMeta(id, []pomeriumconfig.Policy{{To: "foo", From: "bar"}}, RouteMeta{}))
+	assert.False(t, cm.SetWithMeta(id, []pomeriumconfig.Policy{{To: "foo", From: "bar"}}, RouteMeta{Priority: 1}))
+	assert.True(t, cm.SetWithMeta(id, []pomeriumconfig.Policy{{To: "foo", From: "baz"}}, RouteMeta{}))
+
+	assert.NoError(t, cm.Remove(id))
+	assert.True(t, cm.SetWithMeta(id, []pomeriumconfig.Policy{{To: "foo", From: "baz"}}, RouteMeta{}))
+}
+
+func Test_ValidateBaseConfig(t *testing.T) {
+	assert.NoError(t, ValidateBaseConfig(mockBaseConfigBytes(t)))
+	assert.NoError(t, ValidateBaseConfig(nil))
+	assert.Error(t, ValidateBaseConfig([]byte("not,yaml!")))
+	assert.Error(t, ValidateBaseConfig([]byte("services: bogus")), "options must pass pomerium validation")
+}
+
 func Test_SaveLoop(t *testing.T) {
 	cm := NewConfigManager("test", "pomerium", newMockClient(t), time.Nanosecond*1)
 	cm.Set(newIngressResourceIdentifier("test"), []pomeriumconfig.Policy{{To: "foo", From: "bar"}})
@@ -292,3 +348,38 @@ func Test_OnSave(t *testing.T) {
 	assert.Equal(t, 1, callback.called)
 	assert.Empty(t, cmp.Diff(persistedConfig, callback.calledConfig, cmpopts.IgnoreUnexported(pomeriumconfig.Options{})))
 }
+
+func Test_SetCertificates(t *testing.T) {
+	cm := NewConfigManager("test", "pomerium", newMockClient(t), time.Nanosecond*1)
+	err := cm.SetBaseConfig(mockBaseConfigBytes(t))
+	assert.NoError(t, err, "could not set base config")
+
+	shared := Certificate{Cert: []byte("shared-cert"), Key: []byte("shared-key")}
+	cm.SetCertificates(newIngressResourceIdentifier("a"), []Certificate{shared, {Cert: []byte("a-cert"), Key: []byte("a-key")}})
+	cm.SetCertificates(newIngressResourceIdentifier("b"), []Certificate{shared})
+
+	getCertificates := func() []certificateOption {
+		options, err := cm.GetCurrentConfig()
+		assert.NoError(t, err)
+
+		optionBytes, err := yaml.Marshal(options)
+		assert.NoError(t, err)
+
+		var certOptions certificateOptions
+		assert.NoError(t, yaml.Unmarshal(optionBytes, &certOptions))
+		return certOptions.Certificates
+	}
+
+	assert.Equal(t, []certificateOption{
+		{Cert: "c2hhcmVkLWNlcnQ=", Key: "c2hhcmVkLWtleQ=="},
+		{Cert: "YS1jZXJ0", Key: "YS1rZXk="},
+	}, getCertificates())
+
+	assert.NoError(t, cm.Remove(newIngressResourceIdentifier("a")))
+	assert.Equal(t, []certificateOption{
+		{Cert: "c2hhcmVkLWNlcnQ=", Key: "c2hhcmVkLWtleQ=="},
+	}, getCertificates())
+
+	cm.SetCertificates(newIngressResourceIdentifier("b"), nil)
+	assert.Empty(t, getCertificates())
+}
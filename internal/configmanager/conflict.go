@@ -0,0 +1,115 @@
+package configmanager
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	pomeriumconfig "github.com/pomerium/pomerium/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var conflictsGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "pomerium_operator_route_conflicts",
+	Help: "Number of routes claimed by more than one resource",
+})
+
+func init() {
+	metrics.Registry.MustRegister(conflictsGauge)
+}
+
+// RouteMeta decides which resource keeps a route claimed by several resources.  The highest Priority wins, then the
+// oldest CreationTimestamp, then the resource sorting first by namespaced name.  UID identifies the resource to report
+// conflicts against.
+type RouteMeta struct {
+	Priority          int
+	CreationTimestamp time.Time
+	UID               types.UID
+}
+
+// Conflict is a route claimed by more than one resource.  Only the policy of Winner is kept.
+type Conflict struct {
+	Route    string
+	Winner   ResourceIdentifier
+	Loser    ResourceIdentifier
+	LoserUID types.UID
+}
+
+// ConflictReceiver is called with each newly detected Conflict
+type ConflictReceiver func(Conflict)
+
+// OnConflict adds a ConflictReceiver function to call when ConfigManager detects a new conflict while saving
+func (c *ConfigManager) OnConflict(f ConflictReceiver) {
+	c.onConflicts = append(c.onConflicts, f)
+}
+
+// routeKey identifies the requests matched by policy.  Policies with the same key shadow each other.
+func routeKey(policy pomeriumconfig.Policy) string {
+	switch {
+	case policy.Regex != "":
+		return fmt.Sprintf("%s regex %s", policy.From, policy.Regex)
+	case policy.Path != "":
+		return fmt.Sprintf("%s path %s", policy.From, policy.Path)
+	case policy.Prefix != "" && policy.Prefix != "/":
+		return fmt.Sprintf("%s prefix %s", policy.From, policy.Prefix)
+	}
+	return policy.From
+}
+
+// resolveConflicts returns the conflicts between resources in the policy list.  Must be called with the mutex held.
+func (c *ConfigManager) resolveConflicts() []Conflict {
+	var ids []ResourceIdentifier
+	for id := range c.policyList {
+		ids = append(ids, id)
+	}
+	sortResourceIdentifiers(ids)
+	sort.SliceStable(ids, func(i, j int) bool {
+		a, b := c.metaList[ids[i]], c.metaList[ids[j]]
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		return a.CreationTimestamp.Before(b.CreationTimestamp)
+	})
+
+	conflicts := make([]Conflict, 0)
+	owners := make(map[string]ResourceIdentifier)
+	for _, id := range ids {
+		lost := make(map[string]bool)
+		for _, policy := range c.policyList[id] {
+			key := routeKey(policy)
+			owner, claimed := owners[key]
+			if !claimed {
+				owners[key] = id
+				continue
+			}
+			if owner != id && !lost[key] {
+				lost[key] = true
+				conflicts = append(conflicts, Conflict{Route: key, Winner: owner, Loser: id, LoserUID: c.metaList[id].UID})
+			}
+		}
+	}
+	return conflicts
+}
+
+// reportConflicts updates the conflict metric and calls the OnConflict hooks for conflicts not previously reported
+func (c *ConfigManager) reportConflicts(conflicts []Conflict) {
+	c.conflictMutex.Lock()
+	defer c.conflictMutex.Unlock()
+
+	conflictsGauge.Set(float64(len(conflicts)))
+
+	current := make(map[Conflict]bool, len(conflicts))
+	for _, conflict := range conflicts {
+		current[conflict] = true
+		if c.conflicts[conflict] {
+			continue
+		}
+		logger.Info("route conflict", "route", conflict.Route, "winner", conflict.Winner, "loser", conflict.Loser)
+		for _, f := range c.onConflicts {
+			f(conflict)
+		}
+	}
+	c.conflicts = current
+}
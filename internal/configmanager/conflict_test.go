@@ -0,0 +1,104 @@
+package configmanager
+
+import (
+	"testing"
+	"time"
+
+	pomeriumconfig "github.com/pomerium/pomerium/config"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_routeKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy pomeriumconfig.Policy
+		want   string
+	}{
+		{name: "from", policy: pomeriumconfig.Policy{From: "https://a.example.com"}, want: "https://a.example.com"},
+		{name: "root prefix", policy: pomeriumconfig.Policy{From: "https://a.example.com", Prefix: "/"}, want: "https://a.example.com"},
+		{name: "prefix", policy: pomeriumconfig.Policy{From: "https://a.example.com", Prefix: "/api"}, want: "https://a.example.com prefix /api"},
+		{name: "path", policy: pomeriumconfig.Policy{From: "https://a.example.com", Path: "/api"}, want: "https://a.example.com path /api"},
+		{name: "regex", policy: pomeriumconfig.Policy{From: "https://a.example.com", Regex: "^/api"}, want: "https://a.example.com regex ^/api"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, routeKey(tt.policy))
+		})
+	}
+}
+
+func Test_resolveConflicts(t *testing.T) {
+	older := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := older.Add(time.Hour)
+
+	tests := []struct {
+		name       string
+		metaA      RouteMeta
+		metaB      RouteMeta
+		wantWinner string
+	}{
+		{name: "by name", metaA: RouteMeta{CreationTimestamp: older}, metaB: RouteMeta{CreationTimestamp: older}, wantWinner: "a"},
+		{name: "by age", metaA: RouteMeta{CreationTimestamp: newer}, metaB: RouteMeta{CreationTimestamp: older}, wantWinner: "b"},
+		{name: "by priority", metaA: RouteMeta{CreationTimestamp: older}, metaB: RouteMeta{Priority: 1, CreationTimestamp: newer}, wantWinner: "b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cm := NewConfigManager("test", "pomerium", newMockClient(t), time.Nanosecond*1)
+			assert.NoError(t, cm.SetBaseConfig(mockBaseConfigBytes(t)))
+
+			a, b := newIngressResourceIdentifier("a"), newIngressResourceIdentifier("b")
+			cm.SetWithMeta(a, []pomeriumconfig.Policy{
+				{From: "https://shared.example.com", To: "https://to-a.example.com"},
+				{From: "https://a.example.com", To: "https://to-a.example.com"},
+			}, tt.metaA)
+			cm.SetWithMeta(b, []pomeriumconfig.Policy{
+				{From: "https://shared.example.com", To: "https://to-b.example.com"},
+				{From: "https://b.example.com", To: "https://to-b.example.com"},
+			}, tt.metaB)
+
+			winner, loser := a, b
+			if tt.wantWinner == "b" {
+				winner, loser = b, a
+			}
+
+			options, conflicts, err := cm.currentConfig()
+			assert.NoError(t, err)
+			assert.Equal(t, []Conflict{{Route: "https://shared.example.com", Winner: winner, Loser: loser}}, conflicts)
+
+			var froms []string
+			for _, policy := range options.Policies {
+				if policy.From == "https://shared.example.com" {
+					assert.Equal(t, "https://to-"+winner.NamespacedName.Name+".example.com", string(policy.To))
+				}
+				froms = append(froms, policy.From)
+			}
+			assert.ElementsMatch(t, []string{"https://shared.example.com", "https://a.example.com", "https://b.example.com"}, froms)
+		})
+	}
+}
+
+func Test_OnConflict(t *testing.T) {
+	cm := NewConfigManager("test", "pomerium", newMockClient(t), time.Nanosecond*1)
+	assert.NoError(t, cm.SetBaseConfig(mockBaseConfigBytes(t)))
+
+	var got []Conflict
+	cm.OnConflict(func(conflict Conflict) { got = append(got, conflict) })
+
+	a, b := newIngressResourceIdentifier("a"), newIngressResourceIdentifier("b")
+	cm.SetWithMeta(a, []pomeriumconfig.Policy{{From: "https://shared.example.com", To: "https://to-a.example.com"}}, RouteMeta{UID: "uid-a"})
+	cm.SetWithMeta(b, []pomeriumconfig.Policy{{From: "https://shared.example.com", To: "https://to-b.example.com"}}, RouteMeta{UID: "uid-b"})
+
+	assert.NoError(t, cm.Save())
+	assert.Equal(t, []Conflict{{Route: "https://shared.example.com", Winner: a, Loser: b, LoserUID: "uid-b"}}, got)
+	assert.Equal(t, float64(1), testutil.ToFloat64(conflictsGauge))
+
+	assert.NoError(t, cm.Save())
+	assert.Len(t, got, 1, "conflicts are only reported once")
+
+	assert.NoError(t, cm.Remove(b))
+	assert.NoError(t, cm.Save())
+	assert.Equal(t, float64(0), testutil.ToFloat64(conflictsGauge))
+}
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	networkingv1beta1 "k8s.io/api/extensions/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
 
 	"github.com/google/go-cmp/cmp"
 
@@ -62,6 +63,26 @@ func Test_NewResourceIdentifierFromObj(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "ingress-v1",
+			obj: &networkingv1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-ingress",
+					Namespace: "testing",
+				},
+			},
+			wantIdentifier: ResourceIdentifier{
+				GVK: schema.GroupVersionKind{
+					Group:   "networking.k8s.io",
+					Version: "v1",
+					Kind:    "Ingress",
+				},
+				NamespacedName: types.NamespacedName{
+					Name:      "test-ingress",
+					Namespace: "testing",
+				},
+			},
+		},
 	}
 
 	for _, tt := range tests {
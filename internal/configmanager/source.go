@@ -0,0 +1,171 @@
+package configmanager
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// BaseConfigSource is one layer of the base configuration.  Layers are deep-merged in order, so later sources override
+// options set by earlier ones.
+//
+// Sources are written as `file:<path>`, `configmap:<namespace>/<name>[/<key>]` or `secret:<namespace>/<name>[/<key>]`.
+// A value without a prefix is a file path.
+//
+// With a key, the value of the key holds a configuration document.  Without a key, every key of the ConfigMap or
+// Secret sets the option of the same name to its value, e.g. a Secret with `shared_secret` and `cookie_secret` keys.
+type BaseConfigSource struct {
+	Kind           string
+	Path           string
+	NamespacedName types.NamespacedName
+	Key            string
+}
+
+// Base config source kinds
+const (
+	BaseConfigSourceFile      = "file"
+	BaseConfigSourceConfigMap = "configmap"
+	BaseConfigSourceSecret    = "secret"
+)
+
+// ParseBaseConfigSource parses value as a BaseConfigSource
+func ParseBaseConfigSource(value string) (BaseConfigSource, error) {
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) == 1 {
+		parts = []string{BaseConfigSourceFile, value}
+	}
+
+	source := BaseConfigSource{Kind: parts[0]}
+	switch source.Kind {
+	case BaseConfigSourceFile:
+		if parts[1] == "" {
+			return source, fmt.Errorf("%q has no path", value)
+		}
+		source.Path = filepath.Clean(parts[1])
+	case BaseConfigSourceConfigMap, BaseConfigSourceSecret:
+		names := strings.Split(parts[1], "/")
+		if len(names) < 2 || len(names) > 3 || names[0] == "" || names[1] == "" {
+			return source, fmt.Errorf("%q is not in %s:namespace/name[/key] form", value, source.Kind)
+		}
+		source.NamespacedName = types.NamespacedName{Namespace: names[0], Name: names[1]}
+		if len(names) == 3 {
+			if names[2] == "" {
+				return source, fmt.Errorf("%q has an empty key", value)
+			}
+			source.Key = names[2]
+		}
+	default:
+		return source, fmt.Errorf("%q has unknown source type %q", value, source.Kind)
+	}
+	return source, nil
+}
+
+// String implements fmt.Stringer
+func (s BaseConfigSource) String() string {
+	if s.Kind == BaseConfigSourceFile {
+		return fmt.Sprintf("%s:%s", s.Kind, s.Path)
+	}
+	if s.Key != "" {
+		return fmt.Sprintf("%s:%s/%s", s.Kind, s.NamespacedName, s.Key)
+	}
+	return fmt.Sprintf("%s:%s", s.Kind, s.NamespacedName)
+}
+
+// Load reads the configuration document of the source.  c is used to read ConfigMaps and Secrets.
+func (s BaseConfigSource) Load(ctx context.Context, c client.Client) ([]byte, error) {
+	if s.Kind == BaseConfigSourceFile {
+		configBytes, err := ioutil.ReadFile(s.Path)
+		if err != nil {
+			return nil, fmt.Errorf("could not read %s: %w", s, err)
+		}
+		return configBytes, nil
+	}
+
+	var data map[string][]byte
+	if s.Kind == BaseConfigSourceSecret {
+		secret := &corev1.Secret{}
+		if err := c.Get(ctx, s.NamespacedName, secret); err != nil {
+			return nil, fmt.Errorf("could not get %s: %w", s, err)
+		}
+		data = secret.Data
+	} else {
+		configMap := &corev1.ConfigMap{}
+		if err := c.Get(ctx, s.NamespacedName, configMap); err != nil {
+			return nil, fmt.Errorf("could not get %s: %w", s, err)
+		}
+		data = make(map[string][]byte, len(configMap.Data))
+		for k, v := range configMap.Data {
+			data[k] = []byte(v)
+		}
+	}
+
+	if s.Key != "" {
+		configBytes, ok := data[s.Key]
+		if !ok {
+			return nil, fmt.Errorf("%s: key not found", s)
+		}
+		return configBytes, nil
+	}
+
+	options := make(map[string]string, len(data))
+	for k, v := range data {
+		options[k] = string(v)
+	}
+	configBytes, err := yaml.Marshal(options)
+	if err != nil {
+		return nil, fmt.Errorf("could not serialize %s: %w", s, err)
+	}
+	return configBytes, nil
+}
+
+// LoadBaseConfigs reads the configuration documents of sources, in order
+func LoadBaseConfigs(ctx context.Context, c client.Client, sources []BaseConfigSource) ([][]byte, error) {
+	layers := make([][]byte, 0, len(sources))
+	for _, source := range sources {
+		configBytes, err := source.Load(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, configBytes)
+	}
+	return layers, nil
+}
+
+// mergeBaseConfigs deep-merges layers of configuration into a single document.  Nested maps are merged, while any other
+// value set by a later layer replaces the earlier one.
+func mergeBaseConfigs(layers [][]byte) ([]byte, error) {
+	if len(layers) == 1 {
+		return layers[0], nil
+	}
+
+	merged := make(map[interface{}]interface{})
+	for i, layer := range layers {
+		values := make(map[interface{}]interface{})
+		if err := yaml.Unmarshal(layer, &values); err != nil {
+			return nil, fmt.Errorf("could not parse base config layer %d: %w", i, err)
+		}
+		mergeValues(merged, values)
+	}
+
+	return yaml.Marshal(merged)
+}
+
+// mergeValues merges src into dst
+func mergeValues(dst map[interface{}]interface{}, src map[interface{}]interface{}) {
+	for k, v := range src {
+		srcMap, srcIsMap := v.(map[interface{}]interface{})
+		dstMap, dstIsMap := dst[k].(map[interface{}]interface{})
+		if srcIsMap && dstIsMap {
+			mergeValues(dstMap, srcMap)
+			continue
+		}
+		dst[k] = v
+	}
+}
@@ -0,0 +1,146 @@
+package configmanager
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_ParseBaseConfigSource(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    BaseConfigSource
+		wantErr bool
+	}{
+		{value: "./pomerium-base.yaml", want: BaseConfigSource{Kind: BaseConfigSourceFile, Path: "pomerium-base.yaml"}},
+		{value: "file:/etc/pomerium/base.yaml", want: BaseConfigSource{Kind: BaseConfigSourceFile, Path: "/etc/pomerium/base.yaml"}},
+		{
+			value: "configmap:pomerium/base",
+			want:  BaseConfigSource{Kind: BaseConfigSourceConfigMap, NamespacedName: types.NamespacedName{Namespace: "pomerium", Name: "base"}},
+		},
+		{
+			value: "secret:pomerium/secrets/config.yaml",
+			want:  BaseConfigSource{Kind: BaseConfigSourceSecret, NamespacedName: types.NamespacedName{Namespace: "pomerium", Name: "secrets"}, Key: "config.yaml"},
+		},
+		{value: "file:", wantErr: true},
+		{value: "secret:secrets", wantErr: true},
+		{value: "secret:pomerium/secrets/", wantErr: true},
+		{value: "secret:pomerium/secrets/a/b", wantErr: true},
+		{value: "vault:pomerium/secrets", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseBaseConfigSource(tt.value)
+			assert.Equal(t, tt.wantErr, err != nil)
+			if !tt.wantErr {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func Test_BaseConfigSource_Load(t *testing.T) {
+	file, err := ioutil.TempFile("", "base-config-source")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.Remove(file.Name())
+	_, err = file.WriteString("insecure_server: true")
+	assert.NoError(t, err)
+	assert.NoError(t, file.Close())
+
+	c := fake.NewFakeClient(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "base", Namespace: "pomerium"},
+			Data:       map[string]string{"config.yaml": "forward_auth_url: https://forward.beyondcorp.org"},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "secrets", Namespace: "pomerium"},
+			Data:       map[string][]byte{"shared_secret": []byte("c2VjcmV0"), "cookie_secret": []byte("Y29va2ll")},
+		},
+	)
+	pomeriumNamespace := func(name string) types.NamespacedName {
+		return types.NamespacedName{Namespace: "pomerium", Name: name}
+	}
+
+	tests := []struct {
+		name    string
+		source  BaseConfigSource
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name:   "file",
+			source: BaseConfigSource{Kind: BaseConfigSourceFile, Path: file.Name()},
+			want:   map[string]interface{}{"insecure_server": true},
+		},
+		{
+			name:   "configmap key",
+			source: BaseConfigSource{Kind: BaseConfigSourceConfigMap, NamespacedName: pomeriumNamespace("base"), Key: "config.yaml"},
+			want:   map[string]interface{}{"forward_auth_url": "https://forward.beyondcorp.org"},
+		},
+		{
+			name:   "secret keys as options",
+			source: BaseConfigSource{Kind: BaseConfigSourceSecret, NamespacedName: pomeriumNamespace("secrets")},
+			want:   map[string]interface{}{"shared_secret": "c2VjcmV0", "cookie_secret": "Y29va2ll"},
+		},
+		{
+			name:    "missing key",
+			source:  BaseConfigSource{Kind: BaseConfigSourceSecret, NamespacedName: pomeriumNamespace("secrets"), Key: "config.yaml"},
+			wantErr: true,
+		},
+		{
+			name:    "missing secret",
+			source:  BaseConfigSource{Kind: BaseConfigSourceSecret, NamespacedName: pomeriumNamespace("missing")},
+			wantErr: true,
+		},
+		{
+			name:    "missing file",
+			source:  BaseConfigSource{Kind: BaseConfigSourceFile, Path: "/nonexistent/pomerium-base.yaml"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configBytes, err := tt.source.Load(context.Background(), c)
+			assert.Equal(t, tt.wantErr, err != nil)
+			if tt.wantErr {
+				return
+			}
+
+			got := make(map[string]interface{})
+			assert.NoError(t, yaml.Unmarshal(configBytes, &got))
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_mergeBaseConfigs(t *testing.T) {
+	merged, err := mergeBaseConfigs([][]byte{
+		[]byte("a: 1\nnested:\n  b: 2\n  c: 3\nlist: [1, 2]"),
+		[]byte("nested:\n  c: 4\nlist: [3]"),
+		nil,
+	})
+	assert.NoError(t, err)
+
+	got := make(map[string]interface{})
+	assert.NoError(t, yaml.Unmarshal(merged, &got))
+	assert.Equal(t, map[string]interface{}{
+		"a":      1,
+		"nested": map[interface{}]interface{}{"b": 2, "c": 4},
+		"list":   []interface{}{3},
+	}, got)
+
+	_, err = mergeBaseConfigs([][]byte{[]byte("a: 1"), []byte("not,yaml!")})
+	assert.Error(t, err)
+}
@@ -0,0 +1,125 @@
+package configmanager
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// defaultPollPeriod is how often ConfigMap and Secret base config sources are re-read
+const defaultPollPeriod = 30 * time.Second
+
+// BaseConfigWatcher reloads the base configuration of a ConfigManager when one of its sources changes.  Use
+// NewBaseConfigWatcher() to initialize.
+//
+// The directory containing each file source is watched, so a file replaced by renaming, such as a mounted ConfigMap
+// updated by the kubelet swapping its `..data` symlink, is followed.  ConfigMap and Secret sources are polled.  Content
+// which fails to load or parse is ignored and the previous base configuration is kept.
+type BaseConfigWatcher struct {
+	sources       []BaseConfigSource
+	configManager *ConfigManager
+	pollPeriod    time.Duration
+}
+
+// NewBaseConfigWatcher returns a BaseConfigWatcher which sets the base configuration of configManager from sources
+func NewBaseConfigWatcher(sources []BaseConfigSource, configManager *ConfigManager) *BaseConfigWatcher {
+	return &BaseConfigWatcher{sources: sources, configManager: configManager, pollPeriod: defaultPollPeriod}
+}
+
+// Start implements manager.Runnable
+//
+// begins watching the base config sources until ctx is done.  The sources are read once on start, so changes made
+// before leadership was established are picked up.
+func (w *BaseConfigWatcher) Start(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("could not create base config watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	var poll <-chan time.Time
+	watched := make(map[string]bool)
+	for _, source := range w.sources {
+		if source.Kind != BaseConfigSourceFile {
+			if poll == nil {
+				ticker := time.NewTicker(w.pollPeriod)
+				defer ticker.Stop()
+				poll = ticker.C
+			}
+			continue
+		}
+
+		dir := filepath.Dir(source.Path)
+		if watched[dir] {
+			continue
+		}
+		if err := watcher.Add(dir); err != nil {
+			return fmt.Errorf("could not watch base config file %s: %w", source.Path, err)
+		}
+		watched[dir] = true
+		logger.V(1).Info("watching base config directory", "path", dir)
+	}
+
+	w.loopReload(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-poll:
+			w.loopReload(ctx)
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			logger.V(1).Info("base config directory changed", "event", event.String())
+			w.loopReload(ctx)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			logger.Error(err, "error watching base config files")
+		}
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable.
+//
+// Reloading saves the configuration, so only the leader watches the base config sources
+func (w *BaseConfigWatcher) NeedLeaderElection() bool {
+	return true
+}
+
+func (w *BaseConfigWatcher) loopReload(ctx context.Context) {
+	if err := w.reload(ctx); err != nil {
+		logger.Error(err, "failed to reload base config")
+	}
+}
+
+// reload sets the base configuration from the sources and saves immediately if any of them changed
+func (w *BaseConfigWatcher) reload(ctx context.Context) error {
+	layers, err := LoadBaseConfigs(ctx, w.configManager.client, w.sources)
+	if err != nil {
+		return fmt.Errorf("keeping previous base config: %w", err)
+	}
+
+	w.configManager.mutex.RLock()
+	unchanged := reflect.DeepEqual(layers, w.configManager.baseConfigs)
+	w.configManager.mutex.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	if err := w.configManager.SetBaseConfigs(layers); err != nil {
+		return fmt.Errorf("keeping previous base config: %w", err)
+	}
+	logger.Info("reloaded base config", "sources", fmt.Sprint(w.sources))
+
+	return w.configManager.Save()
+}
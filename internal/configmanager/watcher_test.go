@@ -0,0 +1,155 @@
+package configmanager
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func persistedForwardAuthURL(cm *ConfigManager) func() string {
+	return func() string {
+		options, err := cm.GetPersistedConfig()
+		if err != nil {
+			return ""
+		}
+		return options.ForwardAuthURLString
+	}
+}
+
+func startWatcher(t *testing.T, w *BaseConfigWatcher) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		assert.NoError(t, w.Start(ctx))
+	}()
+
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+// replaceFile replaces the content of path by renaming, so the watcher never reads a partially written file
+func replaceFile(t *testing.T, path string, content string) {
+	tmpPath := path + ".tmp"
+	assert.NoError(t, ioutil.WriteFile(tmpPath, []byte(content), 0600))
+	assert.NoError(t, os.Rename(tmpPath, path))
+}
+
+func Test_BaseConfigWatcher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "base-config-watcher")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "pomerium-base.yaml")
+	replaceFile(t, path, "forward_auth_url: https://one.beyondcorp.org")
+
+	cm := NewConfigManager("test", "pomerium", fake.NewFakeClient(), time.Hour)
+	stop := startWatcher(t, NewBaseConfigWatcher([]BaseConfigSource{{Kind: BaseConfigSourceFile, Path: path}}, cm))
+	defer stop()
+
+	persisted := persistedForwardAuthURL(cm)
+	assert.Eventually(t, func() bool { return persisted() == "https://one.beyondcorp.org" }, 5*time.Second, 10*time.Millisecond)
+
+	replaceFile(t, path, "forward_auth_url: https://two.beyondcorp.org")
+	assert.Eventually(t, func() bool { return persisted() == "https://two.beyondcorp.org" }, 5*time.Second, 10*time.Millisecond)
+
+	replaceFile(t, path, "not,yaml!")
+	assert.Never(t, func() bool { return persisted() != "https://two.beyondcorp.org" }, 200*time.Millisecond, 10*time.Millisecond)
+	current, err := cm.GetCurrentConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, "https://two.beyondcorp.org", current.ForwardAuthURLString)
+}
+
+func Test_BaseConfigWatcher_symlinkSwap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "base-config-watcher")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	// Lay out the directory the way the kubelet mounts a ConfigMap
+	writeVersion := func(version string, content string) {
+		versionDir := filepath.Join(dir, version)
+		assert.NoError(t, os.Mkdir(versionDir, 0700))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(versionDir, "pomerium-base.yaml"), []byte(content), 0600))
+
+		tmpLink := filepath.Join(dir, "..data_tmp")
+		assert.NoError(t, os.Symlink(version, tmpLink))
+		assert.NoError(t, os.Rename(tmpLink, filepath.Join(dir, "..data")))
+	}
+	writeVersion("..v1", "forward_auth_url: https://one.beyondcorp.org")
+	path := filepath.Join(dir, "pomerium-base.yaml")
+	assert.NoError(t, os.Symlink(filepath.Join("..data", "pomerium-base.yaml"), path))
+
+	cm := NewConfigManager("test", "pomerium", fake.NewFakeClient(), time.Hour)
+	stop := startWatcher(t, NewBaseConfigWatcher([]BaseConfigSource{{Kind: BaseConfigSourceFile, Path: path}}, cm))
+	defer stop()
+
+	persisted := persistedForwardAuthURL(cm)
+	assert.Eventually(t, func() bool { return persisted() == "https://one.beyondcorp.org" }, 5*time.Second, 10*time.Millisecond)
+
+	writeVersion("..v2", "forward_auth_url: https://two.beyondcorp.org")
+	assert.Eventually(t, func() bool { return persisted() == "https://two.beyondcorp.org" }, 5*time.Second, 10*time.Millisecond)
+}
+
+func Test_BaseConfigWatcher_layers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "base-config-watcher")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "pomerium-base.yaml")
+	replaceFile(t, path, "forward_auth_url: https://one.beyondcorp.org\nshared_secret: placeholder")
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "pomerium-secrets", Namespace: "test"},
+		Data:       map[string][]byte{"shared_secret": []byte("c2VjcmV0")},
+	}
+	c := fake.NewFakeClient(secret)
+	cm := NewConfigManager("test", "pomerium", c, time.Hour)
+	w := NewBaseConfigWatcher([]BaseConfigSource{
+		{Kind: BaseConfigSourceFile, Path: path},
+		{Kind: BaseConfigSourceSecret, NamespacedName: types.NamespacedName{Name: "pomerium-secrets", Namespace: "test"}},
+	}, cm)
+	w.pollPeriod = 10 * time.Millisecond
+	stop := startWatcher(t, w)
+	defer stop()
+
+	persistedSecret := func() string {
+		secret := &corev1.Secret{}
+		if err := c.Get(context.Background(), types.NamespacedName{Name: "pomerium", Namespace: "test"}, secret); err != nil {
+			return ""
+		}
+		return string(secret.Data[configKey])
+	}
+	assert.Eventually(t, func() bool {
+		return strings.Contains(persistedSecret(), "shared_secret: c2VjcmV0") && strings.Contains(persistedSecret(), "https://one.beyondcorp.org")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	secret.Data["shared_secret"] = []byte("cm90YXRlZA==")
+	assert.NoError(t, c.Update(context.Background(), secret))
+	assert.Eventually(t, func() bool { return strings.Contains(persistedSecret(), "shared_secret: cm90YXRlZA==") }, 5*time.Second, 10*time.Millisecond)
+}
+
+func Test_BaseConfigWatcher_missingDirectory(t *testing.T) {
+	cm := NewConfigManager("test", "pomerium", fake.NewFakeClient(), time.Hour)
+	w := NewBaseConfigWatcher([]BaseConfigSource{{Kind: BaseConfigSourceFile, Path: "/nonexistent/pomerium-base.yaml"}}, cm)
+	assert.Error(t, w.Start(context.Background()))
+}
@@ -0,0 +1,120 @@
+package controller
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// AddressStrategy determines how the upstream address of a Service is formed
+type AddressStrategy string
+
+const (
+	// AddressStrategyDNS addresses Services by their cluster DNS name
+	AddressStrategyDNS AddressStrategy = "dns"
+	// AddressStrategyClusterIP addresses Services by their ClusterIP
+	AddressStrategyClusterIP AddressStrategy = "cluster-ip"
+)
+
+// addressStrategyAnnotation overrides the AddressStrategy of the Reconciler for a single resource
+const addressStrategyAnnotation = "pomerium.ingress.kubernetes.io/address-strategy"
+
+// upstreamHostAnnotation replaces the host of every upstream of a single resource with an explicit DNS name or IP
+// address, keeping the port of each backend
+const upstreamHostAnnotation = "pomerium.ingress.kubernetes.io/upstream-host"
+
+const defaultClusterDomain = "cluster.local"
+
+// ParseAddressStrategy converts a string into a supported AddressStrategy
+func ParseAddressStrategy(value string) (AddressStrategy, error) {
+	switch strategy := AddressStrategy(value); strategy {
+	case AddressStrategyDNS, AddressStrategyClusterIP:
+		return strategy, nil
+	}
+	return "", fmt.Errorf("unsupported address strategy %q", value)
+}
+
+// SetClusterDomain sets the cluster DNS domain used to form Service DNS names.  Defaults to cluster.local.
+func (r *Reconciler) SetClusterDomain(domain string) {
+	r.clusterDomain = domain
+}
+
+// SetAddressStrategy sets the default AddressStrategy for upstream Services.  Defaults to AddressStrategyDNS.
+func (r *Reconciler) SetAddressStrategy(strategy AddressStrategy) {
+	r.addressStrategy = strategy
+}
+
+// addressStrategyFor returns the AddressStrategy for a resource, honoring its override annotation.  Resources with an
+// upstream host are addressed by DNS name, as their Services' addresses are replaced anyway.
+func (r *Reconciler) addressStrategyFor(obj metav1.Object) (AddressStrategy, error) {
+	if _, ok := obj.GetAnnotations()[upstreamHostAnnotation]; ok {
+		if _, ok := obj.GetAnnotations()[addressStrategyAnnotation]; ok {
+			return "", fmt.Errorf("cannot be combined with the %s annotation", upstreamHostAnnotation)
+		}
+		return AddressStrategyDNS, nil
+	}
+	if value, ok := obj.GetAnnotations()[addressStrategyAnnotation]; ok {
+		return ParseAddressStrategy(value)
+	}
+	if r.addressStrategy == "" {
+		return AddressStrategyDNS, nil
+	}
+	return r.addressStrategy, nil
+}
+
+// serviceHostPort returns the host:port address of a Service port according to strategy.  service is only required
+// for AddressStrategyClusterIP.
+func (r *Reconciler) serviceHostPort(name string, namespace string, service *corev1.Service, port int32, strategy AddressStrategy) (string, error) {
+	var host string
+
+	switch strategy {
+	case AddressStrategyClusterIP:
+		if service == nil || service.Spec.ClusterIP == "" || service.Spec.ClusterIP == corev1.ClusterIPNone {
+			return "", validationError{fmt.Errorf("service %s/%s has no cluster IP", namespace, name)}
+		}
+		host = service.Spec.ClusterIP
+	default:
+		clusterDomain := r.clusterDomain
+		if clusterDomain == "" {
+			clusterDomain = defaultClusterDomain
+		}
+		host = fmt.Sprintf("%s.%s.svc.%s", name, namespace, clusterDomain)
+	}
+
+	return net.JoinHostPort(host, strconv.Itoa(int(port))), nil
+}
+
+// upstreamHostFor returns the upstream host of a resource set by its override annotation, or "" if it has none
+func upstreamHostFor(obj metav1.Object) (string, error) {
+	host, ok := obj.GetAnnotations()[upstreamHostAnnotation]
+	if !ok {
+		return "", nil
+	}
+
+	host = strings.TrimSpace(host)
+	if net.ParseIP(host) != nil {
+		return host, nil
+	}
+	if problems := validation.IsDNS1123Subdomain(host); len(problems) > 0 {
+		return "", fmt.Errorf("%q is not a DNS name or IP address: %s", host, strings.Join(problems, ", "))
+	}
+	return host, nil
+}
+
+// withUpstreamHost returns upstream addressed to host, keeping its scheme and port
+func withUpstreamHost(upstream url.URL, host string) url.URL {
+	if port := upstream.Port(); port != "" {
+		upstream.Host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		upstream.Host = "[" + host + "]"
+	} else {
+		upstream.Host = host
+	}
+	return upstream
+}
@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_ParseAddressStrategy(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    AddressStrategy
+		wantErr bool
+	}{
+		{value: "dns", want: AddressStrategyDNS},
+		{value: "cluster-ip", want: AddressStrategyClusterIP},
+		{value: "", wantErr: true},
+		{value: "carrier-pigeon", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseAddressStrategy(tt.value)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_addressStrategyFor(t *testing.T) {
+	r := &Reconciler{}
+	strategy, err := r.addressStrategyFor(&metav1.ObjectMeta{})
+	assert.NoError(t, err)
+	assert.Equal(t, AddressStrategyDNS, strategy)
+
+	r.SetAddressStrategy(AddressStrategyClusterIP)
+	strategy, err = r.addressStrategyFor(&metav1.ObjectMeta{})
+	assert.NoError(t, err)
+	assert.Equal(t, AddressStrategyClusterIP, strategy)
+
+	strategy, err = r.addressStrategyFor(&metav1.ObjectMeta{Annotations: map[string]string{addressStrategyAnnotation: "dns"}})
+	assert.NoError(t, err)
+	assert.Equal(t, AddressStrategyDNS, strategy)
+
+	_, err = r.addressStrategyFor(&metav1.ObjectMeta{Annotations: map[string]string{addressStrategyAnnotation: "bogus"}})
+	assert.Error(t, err)
+
+	strategy, err = r.addressStrategyFor(&metav1.ObjectMeta{Annotations: map[string]string{upstreamHostAnnotation: "backend.example.internal"}})
+	assert.NoError(t, err)
+	assert.Equal(t, AddressStrategyDNS, strategy)
+
+	_, err = r.addressStrategyFor(&metav1.ObjectMeta{Annotations: map[string]string{
+		upstreamHostAnnotation:    "backend.example.internal",
+		addressStrategyAnnotation: "cluster-ip",
+	}})
+	assert.Error(t, err)
+}
+
+func Test_upstreamHostFor(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: "backend.example.internal", want: "backend.example.internal"},
+		{value: " 10.0.0.1 ", want: "10.0.0.1"},
+		{value: "fd00::1", want: "fd00::1"},
+		{value: "", wantErr: true},
+		{value: "backend.example.internal:8080", wantErr: true},
+		{value: "http://backend", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := upstreamHostFor(&metav1.ObjectMeta{Annotations: map[string]string{upstreamHostAnnotation: tt.value}})
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	host, err := upstreamHostFor(&metav1.ObjectMeta{})
+	assert.NoError(t, err)
+	assert.Empty(t, host)
+}
+
+func Test_withUpstreamHost(t *testing.T) {
+	upstream := url.URL{Scheme: "https", Host: "service.default.svc.cluster.local:8443"}
+	got := withUpstreamHost(upstream, "backend.example.internal")
+	assert.Equal(t, "https://backend.example.internal:8443", got.String())
+
+	got = withUpstreamHost(upstream, "fd00::1")
+	assert.Equal(t, "https://[fd00::1]:8443", got.String())
+
+	got = withUpstreamHost(url.URL{Scheme: "http", Host: "service"}, "10.0.0.1")
+	assert.Equal(t, "http://10.0.0.1", got.String())
+}
+
+func Test_serviceHostPort(t *testing.T) {
+	service := &corev1.Service{Spec: corev1.ServiceSpec{ClusterIP: "fd00::10"}}
+	headless := &corev1.Service{Spec: corev1.ServiceSpec{ClusterIP: corev1.ClusterIPNone}}
+
+	tests := []struct {
+		name          string
+		clusterDomain string
+		service       *corev1.Service
+		strategy      AddressStrategy
+		want          string
+		wantErr       bool
+	}{
+		{name: "default domain", strategy: AddressStrategyDNS, want: "svc.test.svc.cluster.local:443"},
+		{name: "custom domain", clusterDomain: "corp.example", strategy: AddressStrategyDNS, want: "svc.test.svc.corp.example:443"},
+		{name: "ipv6 cluster ip", service: service, strategy: AddressStrategyClusterIP, want: "[fd00::10]:443"},
+		{name: "headless", service: headless, strategy: AddressStrategyClusterIP, wantErr: true},
+		{name: "no service", strategy: AddressStrategyClusterIP, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Reconciler{}
+			r.SetClusterDomain(tt.clusterDomain)
+			got, err := r.serviceHostPort("svc", "test", tt.service, 443, tt.strategy)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/pomerium/pomerium-operator/api/v1alpha1"
+	"github.com/pomerium/pomerium-operator/internal/configmanager"
+	pomeriumconfig "github.com/pomerium/pomerium/config"
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// ConfigReconciler implements a Kubernetes reconciler for the PomeriumConfig resource holding the base configuration.  Use
+// NewConfigReconciler() to initialize.
+//
+// Only the PomeriumConfig with the configured name is used.  The v1alpha1 types must be registered with the client-go
+// scheme.
+type ConfigReconciler struct {
+	*Reconciler
+	name string
+}
+
+// NewConfigReconciler returns a new ConfigReconciler which sets the base configuration of configManager from the
+// PomeriumConfig called name
+func NewConfigReconciler(name string, configManager *configmanager.ConfigManager) *ConfigReconciler {
+	return &ConfigReconciler{
+		Reconciler: NewReconciler(&v1alpha1.PomeriumConfig{}, "", configManager),
+		name:       name,
+	}
+}
+
+// Reconcile implements the Reconciler interface for PomeriumConfigs.  The Accepted condition of the PomeriumConfig
+// reports the result.
+//
+// An invalid or deleted PomeriumConfig leaves the last accepted base configuration in place.
+func (r *ConfigReconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	if req.Name != r.name {
+		logger.V(1).Info("ignoring pomerium config", "name", req.Name)
+		return reconcile.Result{}, nil
+	}
+	logger.V(1).Info("notified of change to pomerium config", "name", req.Name)
+
+	config := &v1alpha1.PomeriumConfig{}
+	if err := r.Get(ctx, req.NamespacedName, config); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return reconcile.Result{}, fmt.Errorf("could not get pomerium config %s: %w", req.Name, err)
+		}
+
+		logger.Info("pomerium config deleted.  keeping last base config", "name", req.Name)
+		return reconcile.Result{}, nil
+	}
+
+	baseConfig, err := BaseConfigFromSpec(config.Spec)
+	if err == nil {
+		err = r.configManager.SetBaseConfig(baseConfig)
+	}
+	if err != nil {
+		r.event(config, corev1.EventTypeWarning, reasonInvalidConfig, "%s", err)
+		return reconcile.Result{}, r.updateConfigStatus(ctx, config, metav1.ConditionFalse, reasonInvalidConfig, err.Error())
+	}
+
+	r.event(config, corev1.EventTypeNormal, reasonConfigAccepted, "accepted base configuration")
+	return reconcile.Result{}, r.updateConfigStatus(ctx, config, metav1.ConditionTrue, reasonConfigAccepted, "base configuration accepted")
+}
+
+// BaseConfigFromSpec returns the options of spec as a base configuration for ConfigManager
+func BaseConfigFromSpec(spec v1alpha1.PomeriumConfigSpec) ([]byte, error) {
+	options := make(map[string]interface{})
+	if len(spec.Options.Raw) > 0 {
+		if err := json.Unmarshal(spec.Options.Raw, &options); err != nil {
+			return nil, fmt.Errorf("options must be an object: %w", err)
+		}
+	}
+
+	baseConfig, err := yaml.Marshal(options)
+	if err != nil {
+		return nil, fmt.Errorf("could not serialize options: %w", err)
+	}
+
+	if err := configmanager.ValidateBaseConfig(baseConfig); err != nil {
+		return nil, err
+	}
+	return baseConfig, nil
+}
+
+// updateConfigStatus sets the Accepted condition of config, writing the status only when it changes
+func (r *ConfigReconciler) updateConfigStatus(ctx context.Context, config *v1alpha1.PomeriumConfig, status metav1.ConditionStatus, reason string, message string) error {
+	original := config.Status.DeepCopy()
+
+	config.Status.ObservedGeneration = config.Generation
+	meta.SetStatusCondition(&config.Status.Conditions, metav1.Condition{
+		Type:               v1alpha1.ConfigConditionAccepted,
+		Status:             status,
+		ObservedGeneration: config.Generation,
+		Reason:             reason,
+		Message:            message,
+	})
+
+	if reflect.DeepEqual(original, &config.Status) {
+		return nil
+	}
+
+	if err := r.Status().Update(ctx, config); err != nil {
+		return fmt.Errorf("could not update status of pomerium config %s: %w", config.Name, err)
+	}
+	return nil
+}
+
+// RecordSave implements configmanager.ConfigReceiver, recording the checksum and time of the saved configuration in the
+// status of the PomeriumConfig
+func (r *ConfigReconciler) RecordSave(options pomeriumconfig.Options) {
+	if r.Client == nil {
+		return
+	}
+
+	optionBytes, err := yaml.Marshal(options)
+	if err != nil {
+		logger.Error(err, "could not serialize saved config")
+		return
+	}
+	checksum := fmt.Sprintf("%x", sha256.Sum256(optionBytes))
+	now := metav1.Now()
+
+	ctx := context.Background()
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		config := &v1alpha1.PomeriumConfig{}
+		if err := r.Get(ctx, types.NamespacedName{Name: r.name}, config); err != nil {
+			return err
+		}
+
+		config.Status.Checksum = checksum
+		config.Status.LastSaved = &now
+		return r.Status().Update(ctx, config)
+	})
+	if apierrors.IsNotFound(err) {
+		logger.V(1).Info("pomerium config not found.  not recording save", "name", r.name)
+		return
+	}
+	if err != nil {
+		logger.Error(err, "could not record save in pomerium config status", "name", r.name)
+	}
+}
@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pomerium/pomerium-operator/api/v1alpha1"
+	"github.com/pomerium/pomerium-operator/internal/configmanager"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func newTestPomeriumConfig(name string, options string) *v1alpha1.PomeriumConfig {
+	return &v1alpha1.PomeriumConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Generation: 1},
+		Spec:       v1alpha1.PomeriumConfigSpec{Options: runtime.RawExtension{Raw: []byte(options)}},
+	}
+}
+
+func Test_ConfigReconciler_Reconcile(t *testing.T) {
+	tests := []struct {
+		name          string
+		config        *v1alpha1.PomeriumConfig
+		wantStatus    metav1.ConditionStatus
+		wantReason    string
+		wantForwardTo string
+	}{
+		{
+			name:          "accepted",
+			config:        newTestPomeriumConfig("pomerium", `{"forward_auth_url": "https://forward.beyondcorp.org"}`),
+			wantStatus:    metav1.ConditionTrue,
+			wantReason:    reasonConfigAccepted,
+			wantForwardTo: "https://forward.beyondcorp.org",
+		},
+		{
+			name:       "invalid options",
+			config:     newTestPomeriumConfig("pomerium", `{"insecure_server": "not a bool"}`),
+			wantStatus: metav1.ConditionFalse,
+			wantReason: reasonInvalidConfig,
+		},
+		{
+			name:       "options failing validation",
+			config:     newTestPomeriumConfig("pomerium", `{"services": "bogus"}`),
+			wantStatus: metav1.ConditionFalse,
+			wantReason: reasonInvalidConfig,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewFakeClient(tt.config)
+			cm := configmanager.NewConfigManager("test", "test", c, time.Nanosecond*1)
+			r := NewConfigReconciler("pomerium", cm)
+			assert.NoError(t, r.InjectClient(c))
+
+			request := reconcile.Request{NamespacedName: types.NamespacedName{Name: "pomerium"}}
+			_, err := r.Reconcile(context.Background(), request)
+			assert.NoError(t, err)
+
+			options, err := cm.GetCurrentConfig()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantForwardTo, options.ForwardAuthURLString)
+
+			config := &v1alpha1.PomeriumConfig{}
+			assert.NoError(t, c.Get(context.Background(), request.NamespacedName, config))
+			condition := meta.FindStatusCondition(config.Status.Conditions, v1alpha1.ConfigConditionAccepted)
+			if assert.NotNil(t, condition) {
+				assert.Equal(t, tt.wantStatus, condition.Status)
+				assert.Equal(t, tt.wantReason, condition.Reason)
+			}
+			assert.Equal(t, int64(1), config.Status.ObservedGeneration)
+		})
+	}
+}
+
+func Test_ConfigReconciler_Reconcile_keepsLastConfig(t *testing.T) {
+	c := fake.NewFakeClient(
+		newTestPomeriumConfig("pomerium", `{"forward_auth_url": "https://forward.beyondcorp.org"}`),
+		newTestPomeriumConfig("other", `{"forward_auth_url": "https://other.beyondcorp.org"}`),
+	)
+	cm := configmanager.NewConfigManager("test", "test", c, time.Nanosecond*1)
+	r := NewConfigReconciler("pomerium", cm)
+	assert.NoError(t, r.InjectClient(c))
+
+	reconcileName := func(name string) {
+		_, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Name: name}})
+		assert.NoError(t, err)
+	}
+	forwardAuthURL := func() string {
+		options, err := cm.GetCurrentConfig()
+		assert.NoError(t, err)
+		return options.ForwardAuthURLString
+	}
+
+	reconcileName("pomerium")
+	reconcileName("other")
+	assert.Equal(t, "https://forward.beyondcorp.org", forwardAuthURL())
+
+	config := &v1alpha1.PomeriumConfig{}
+	assert.NoError(t, c.Get(context.Background(), types.NamespacedName{Name: "pomerium"}, config))
+	config.Spec.Options.Raw = []byte(`["not", "an", "object"]`)
+	assert.NoError(t, c.Update(context.Background(), config))
+	reconcileName("pomerium")
+	assert.Equal(t, "https://forward.beyondcorp.org", forwardAuthURL())
+
+	assert.NoError(t, c.Delete(context.Background(), config))
+	reconcileName("pomerium")
+	assert.Equal(t, "https://forward.beyondcorp.org", forwardAuthURL())
+}
+
+func Test_ConfigReconciler_RecordSave(t *testing.T) {
+	c := fake.NewFakeClient(newTestPomeriumConfig("pomerium", `{}`))
+	cm := configmanager.NewConfigManager("test", "test", c, time.Nanosecond*1)
+	r := NewConfigReconciler("pomerium", cm)
+	assert.NoError(t, r.InjectClient(c))
+	cm.OnSave(r.RecordSave)
+
+	_, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Name: "pomerium"}})
+	assert.NoError(t, err)
+	assert.NoError(t, cm.Save())
+
+	config := &v1alpha1.PomeriumConfig{}
+	assert.NoError(t, c.Get(context.Background(), types.NamespacedName{Name: "pomerium"}, config))
+	assert.Len(t, config.Status.Checksum, 64)
+	assert.NotNil(t, config.Status.LastSaved)
+}
@@ -0,0 +1,46 @@
+package controller
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/pomerium/pomerium-operator/internal/configmanager"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// priorityAnnotation sets the priority of a resource's routes when several resources claim the same route.  The highest
+// priority wins, then the oldest resource.
+const priorityAnnotation = "pomerium.ingress.kubernetes.io/priority"
+
+// routeMetaFromObj returns the RouteMeta deciding route conflicts for obj
+func routeMetaFromObj(obj metav1.Object) (configmanager.RouteMeta, error) {
+	meta := configmanager.RouteMeta{CreationTimestamp: obj.GetCreationTimestamp().Time, UID: obj.GetUID()}
+
+	value, ok := obj.GetAnnotations()[priorityAnnotation]
+	if !ok {
+		return meta, nil
+	}
+
+	priority, err := strconv.Atoi(value)
+	if err != nil {
+		return meta, fmt.Errorf("%q is not an integer", value)
+	}
+	meta.Priority = priority
+	return meta, nil
+}
+
+// RecordConflicts returns a configmanager.ConflictReceiver which records a Warning event against the resource losing
+// each route conflict
+func RecordConflicts(recorder record.EventRecorder) configmanager.ConflictReceiver {
+	return func(conflict configmanager.Conflict) {
+		loser := &metav1.PartialObjectMetadata{}
+		loser.SetGroupVersionKind(conflict.Loser.GVK)
+		loser.SetName(conflict.Loser.NamespacedName.Name)
+		loser.SetNamespace(conflict.Loser.NamespacedName.Namespace)
+		loser.SetUID(conflict.LoserUID)
+
+		recorder.Eventf(loser, corev1.EventTypeWarning, reasonRouteConflict, "route %s is already claimed by %s %s", conflict.Route, conflict.Winner.GVK.Kind, conflict.Winner.NamespacedName)
+	}
+}
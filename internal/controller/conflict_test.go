@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pomerium/pomerium-operator/internal/configmanager"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func Test_routeMetaFromObj(t *testing.T) {
+	created := metav1.NewTime(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        configmanager.RouteMeta
+		wantErr     bool
+	}{
+		{name: "default", want: configmanager.RouteMeta{CreationTimestamp: created.Time, UID: "uid"}},
+		{name: "priority", annotations: map[string]string{priorityAnnotation: "10"}, want: configmanager.RouteMeta{Priority: 10, CreationTimestamp: created.Time, UID: "uid"}},
+		{name: "invalid", annotations: map[string]string{priorityAnnotation: "high"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Annotations: tt.annotations, CreationTimestamp: created, UID: "uid"}}
+			got, err := routeMetaFromObj(obj)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_RecordConflicts(t *testing.T) {
+	annotations := func(priority string) map[string]string {
+		return map[string]string{
+			"ingress.pomerium.io/from":           "https://shared.lan.beyondcorp.org",
+			"ingress.pomerium.io/allowed_groups": `["foo"]`,
+			priorityAnnotation:                   priority,
+		}
+	}
+	ports := corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "https", Port: 443}}}
+	low := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "test", Annotations: annotations("0")}, Spec: ports}
+	high := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "b", Namespace: "test", Annotations: annotations("5")}, Spec: ports}
+
+	c := fake.NewFakeClient(low, high)
+	cm := configmanager.NewConfigManager("test", "test", c, time.Nanosecond*1)
+	recorder := record.NewFakeRecorder(10)
+	cm.OnConflict(RecordConflicts(recorder))
+
+	r := NewReconciler(&corev1.Service{}, "pomerium", cm)
+	assert.NoError(t, r.InjectClient(c))
+	for _, name := range []string{"a", "b"} {
+		_, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: "test"}})
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, cm.Save())
+
+	select {
+	case event := <-recorder.Events:
+		assert.Equal(t, "Warning RouteConflict route https://shared.lan.beyondcorp.org is already claimed by Service test/b", event)
+	default:
+		assert.Fail(t, "no event recorded")
+	}
+
+	options, err := cm.GetCurrentConfig()
+	assert.NoError(t, err)
+	assert.Len(t, options.Policies, 1)
+}
+
+// objectRecorder is a record.EventRecorder keeping the objects events are recorded against
+type objectRecorder struct {
+	*record.FakeRecorder
+	objects []runtime.Object
+}
+
+func (r *objectRecorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.objects = append(r.objects, object)
+	r.FakeRecorder.Eventf(object, eventtype, reason, messageFmt, args...)
+}
+
+func Test_RecordConflicts_uid(t *testing.T) {
+	recorder := &objectRecorder{FakeRecorder: record.NewFakeRecorder(10)}
+	loser := configmanager.ResourceIdentifier{
+		GVK:            schema.GroupVersionKind{Version: "v1", Kind: "Service"},
+		NamespacedName: types.NamespacedName{Name: "a", Namespace: "test"},
+	}
+	RecordConflicts(recorder)(configmanager.Conflict{Route: "https://shared.lan.beyondcorp.org", Winner: loser, Loser: loser, LoserUID: "uid-a"})
+
+	if assert.Len(t, recorder.objects, 1) {
+		obj, ok := recorder.objects[0].(*metav1.PartialObjectMetadata)
+		if assert.True(t, ok) {
+			assert.Equal(t, types.UID("uid-a"), obj.GetUID())
+			assert.Equal(t, "a", obj.GetName())
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"errors"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+)
+
+// Event reasons recorded against reconciled resources
+const (
+	reasonRouteAccepted       = "RouteAccepted"
+	reasonInvalidPolicy       = "InvalidPolicy"
+	reasonInvalidAnnotation   = "InvalidAnnotation"
+	reasonUnresolvableBackend = "UnresolvableBackend"
+	reasonInvalidTLSSecret    = "InvalidTLSSecret"
+	reasonUnresolvableSecret  = "UnresolvableSecret"
+	reasonRouteConflict       = "RouteConflict"
+	reasonForbiddenPolicy     = "ForbiddenPolicy"
+	reasonForbiddenHost       = "ForbiddenHost"
+	reasonConfigAccepted      = "ConfigAccepted"
+	reasonInvalidConfig       = "InvalidConfig"
+)
+
+// SetEventRecorder sets the recorder used to report route acceptance and problems as Events on reconciled resources
+func (r *Reconciler) SetEventRecorder(recorder record.EventRecorder) {
+	r.recorder = recorder
+}
+
+// event records an Event against obj if an event recorder has been set
+func (r *Reconciler) event(obj runtime.Object, eventType string, reason string, messageFmt string, args ...interface{}) {
+	if r.recorder == nil {
+		return
+	}
+	r.recorder.Eventf(obj, eventType, reason, messageFmt, args...)
+}
+
+// reportedError marks an error which has already been recorded as an Event against the resource it concerns
+type reportedError struct {
+	error
+}
+
+func (e reportedError) Unwrap() error {
+	return e.error
+}
+
+// isReported determines if err has already been recorded as an Event
+func isReported(err error) bool {
+	return errors.As(err, &reportedError{})
+}
+
+// validationError marks an error caused by invalid resources or operator configuration, which cannot succeed until
+// they change
+type validationError struct {
+	error
+}
+
+func (e validationError) Unwrap() error {
+	return e.error
+}
+
+// isTransient determines if err may succeed when retried.  Everything but missing objects and validation errors is
+// transient, e.g. API server, network and informer cache errors.  Missing objects are reconciled again by the watches
+// on them once they are created, and invalid resources once they change.
+func isTransient(err error) bool {
+	return !apierrors.IsNotFound(err) && !errors.As(err, &validationError{})
+}
@@ -0,0 +1,176 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pomerium/pomerium-operator/internal/configmanager"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func Test_Reconcile_events(t *testing.T) {
+	tests := []struct {
+		name       string
+		obj        client.Object
+		wantPrefix string
+		wantErr    bool
+	}{
+		{
+			name: "accepted",
+			obj: &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "service",
+					Namespace: "test",
+					Annotations: map[string]string{
+						"ingress.pomerium.io/allowed_groups": `["foo","bar"]`,
+						"ingress.pomerium.io/from":           `https://test.lan.beyondcorp.org`,
+					},
+				},
+				Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "https", Port: 443}}},
+			},
+			wantPrefix: "Normal RouteAccepted",
+		},
+		{
+			name: "invalid policy",
+			obj: &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "service",
+					Namespace: "test",
+					Annotations: map[string]string{
+						"ingress.pomerium.io/allowed_groups": `["foo","bar"]`,
+					},
+				},
+				Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "https", Port: 443}}},
+			},
+			wantPrefix: "Warning InvalidPolicy",
+		},
+		{
+			name: "invalid annotation",
+			obj: &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "service",
+					Namespace: "test",
+					Annotations: map[string]string{
+						"ingress.pomerium.io/allowed_groups": `["foo","bar"`,
+						"ingress.pomerium.io/from":           `https://test.lan.beyondcorp.org`,
+					},
+				},
+				Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "https", Port: 443}}},
+			},
+			wantPrefix: "Warning InvalidAnnotation",
+		},
+		{
+			name: "unresolvable backend",
+			obj: &networkingv1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "ingress",
+					Namespace: "test",
+					Annotations: map[string]string{
+						"ingress.pomerium.io/allowed_groups": `["foo","bar"]`,
+					},
+				},
+				Spec: networkingv1.IngressSpec{
+					DefaultBackend: &networkingv1.IngressBackend{
+						Service: &networkingv1.IngressServiceBackend{
+							Name: "missing",
+							Port: networkingv1.ServiceBackendPort{Name: "https"},
+						},
+					},
+				},
+			},
+			wantPrefix: "Warning UnresolvableBackend",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewFakeClient(tt.obj.DeepCopyObject())
+			r := NewReconciler(tt.obj.DeepCopyObject(), "pomerium", configmanager.NewConfigManager("test", "test", c, time.Nanosecond*1))
+			assert.NoError(t, r.InjectClient(c))
+			recorder := record.NewFakeRecorder(10)
+			r.SetEventRecorder(recorder)
+
+			_, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: client.ObjectKeyFromObject(tt.obj)})
+			assert.Equal(t, tt.wantErr, err != nil)
+
+			select {
+			case event := <-recorder.Events:
+				assert.True(t, strings.HasPrefix(event, tt.wantPrefix), "unexpected event %q", event)
+			default:
+				assert.Fail(t, "no event recorded")
+			}
+		})
+	}
+}
+
+func Test_Reconcile_eventsUnchanged(t *testing.T) {
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "service",
+			Namespace: "test",
+			Annotations: map[string]string{
+				"ingress.pomerium.io/from": `https://test.lan.beyondcorp.org`,
+			},
+		},
+		Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "https", Port: 443}}},
+	}
+
+	c := fake.NewFakeClient(service)
+	r := NewReconciler(&corev1.Service{}, "pomerium", configmanager.NewConfigManager("test", "test", c, time.Nanosecond*1))
+	assert.NoError(t, r.InjectClient(c))
+	recorder := record.NewFakeRecorder(10)
+	r.SetEventRecorder(recorder)
+
+	req := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(service)}
+	_, err := r.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Len(t, recorder.Events, 1)
+	<-recorder.Events
+
+	_, err = r.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Len(t, recorder.Events, 0, "unchanged policy should not be recorded again")
+
+	service.Annotations["ingress.pomerium.io/from"] = `https://other.lan.beyondcorp.org`
+	assert.NoError(t, c.Update(context.Background(), service))
+	_, err = r.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Len(t, recorder.Events, 1)
+}
+
+func Test_isTransient(t *testing.T) {
+	notFound := apierrors.NewNotFound(schema.GroupResource{Resource: "services"}, "service")
+	invalid := validationError{errors.New("could not find port https on service test/service")}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"server timeout", apierrors.NewServerTimeout(schema.GroupResource{Resource: "services"}, "get", 1), true},
+		{"deadline exceeded", fmt.Errorf("could not get service: %w", context.DeadlineExceeded), true},
+		{"network error", errors.New("dial tcp 10.96.0.1:443: connect: connection refused"), true},
+		{"not found", notFound, false},
+		{"reported not found", reportedError{fmt.Errorf("failed to form address for backend: %w", notFound)}, false},
+		{"validation", invalid, false},
+		{"reported validation", reportedError{fmt.Errorf("failed to form address for backend: %w", invalid)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isTransient(tt.err))
+		})
+	}
+}
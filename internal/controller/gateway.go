@@ -0,0 +1,426 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// routeParentStatus is the status of an HTTPRoute for one of its parents
+type routeParentStatus struct {
+	ParentRef      parentReference    `json:"parentRef"`
+	ControllerName string             `json:"controllerName"`
+	Conditions     []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// rejectedParent is a parentRef of an HTTPRoute which does not accept the route, and the reason why
+type rejectedParent struct {
+	ref     parentReference
+	reason  string
+	message string
+}
+
+// gatewayListener is the part of a Gateway listener deciding which HTTPRoutes attach to it and defaulting their
+// hostnames
+type gatewayListener struct {
+	Name          string         `json:"name"`
+	Hostname      *string        `json:"hostname,omitempty"`
+	AllowedRoutes *allowedRoutes `json:"allowedRoutes,omitempty"`
+}
+
+type allowedRoutes struct {
+	Namespaces *routeNamespaces `json:"namespaces,omitempty"`
+	Kinds      []routeGroupKind `json:"kinds,omitempty"`
+}
+
+type routeNamespaces struct {
+	From     *string               `json:"from,omitempty"`
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+}
+
+type routeGroupKind struct {
+	Group *string `json:"group,omitempty"`
+	Kind  string  `json:"kind"`
+}
+
+// parentGateway returns the Gateway referenced by ref from an HTTPRoute in namespace, if ref references a Gateway
+func parentGateway(ref parentReference, namespace string) (types.NamespacedName, bool) {
+	if (ref.Group != nil && *ref.Group != GatewayGroup) || (ref.Kind != nil && *ref.Kind != "Gateway") {
+		return types.NamespacedName{}, false
+	}
+	if ref.Namespace != nil {
+		namespace = *ref.Namespace
+	}
+	return types.NamespacedName{Name: ref.Name, Namespace: namespace}, true
+}
+
+// acceptedParents returns the parentRefs of route referencing a Gateway of a GatewayClass handled by the
+// HTTPRouteReconciler, and the hostnames of route served by the listeners of those Gateways.  Parents whose listeners
+// do not allow route, or serve none of its hostnames, are returned as rejected.  Parents which do not exist are skipped.
+func (r *HTTPRouteReconciler) acceptedParents(ctx context.Context, route client.Object, spec httpRouteSpec) (parents []parentReference, rejected []rejectedParent, hostnames []string, err error) {
+	parents = make([]parentReference, 0)
+	rejected = make([]rejectedParent, 0)
+	hostnames = make([]string, 0)
+	for _, ref := range spec.ParentRefs {
+		gatewayName, ok := parentGateway(ref, route.GetNamespace())
+		if !ok {
+			continue
+		}
+
+		gateway := NewGatewayObject(r.version, "Gateway")
+		if err := r.Get(ctx, gatewayName, gateway); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, nil, nil, fmt.Errorf("could not get gateway %s: %w", gatewayName, err)
+		}
+
+		handled, err := r.handlesGateway(ctx, gateway)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if !handled {
+			continue
+		}
+
+		allowed := false
+		anyHostname := false
+		listenerHostnames := make([]string, 0)
+		listeners, _, _ := unstructured.NestedSlice(gateway.Object, "spec", "listeners")
+		for _, l := range listeners {
+			listenerObj, ok := l.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			listener := gatewayListener{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(listenerObj, &listener); err != nil {
+				continue
+			}
+			if ref.SectionName != nil && *ref.SectionName != listener.Name {
+				continue
+			}
+
+			ok, err := r.listenerAllowsRoute(ctx, listener, gateway.GetNamespace(), route.GetNamespace())
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			if !ok {
+				continue
+			}
+			allowed = true
+			if listener.Hostname != nil && *listener.Hostname != "" {
+				listenerHostnames = append(listenerHostnames, *listener.Hostname)
+			} else {
+				anyHostname = true
+			}
+		}
+
+		if !allowed {
+			rejected = append(rejected, rejectedParent{ref, reasonNotAllowedByListeners, "route is not allowed by the listeners of the gateway"})
+			continue
+		}
+		parentHostnames := listenerRouteHostnames(spec.Hostnames, listenerHostnames, anyHostname)
+		if len(spec.Hostnames) > 0 && len(parentHostnames) == 0 {
+			rejected = append(rejected, rejectedParent{ref, reasonNoHostnames, "no hostname of the route matches the listeners of the gateway"})
+			continue
+		}
+		parents = append(parents, ref)
+		for _, hostname := range parentHostnames {
+			if !containsString(hostnames, hostname) {
+				hostnames = append(hostnames, hostname)
+			}
+		}
+	}
+	return parents, rejected, hostnames, nil
+}
+
+// listenerRouteHostnames returns the hostnames of an HTTPRoute served by listeners with listenerHostnames.  Routes
+// without hostnames take those of the listeners, and when anyHostname is set a listener without a hostname serves every
+// hostname of the route.
+func listenerRouteHostnames(routeHostnames []string, listenerHostnames []string, anyHostname bool) []string {
+	if len(routeHostnames) == 0 {
+		return listenerHostnames
+	}
+
+	hostnames := make([]string, 0)
+	for _, routeHostname := range routeHostnames {
+		matched := make([]string, 0, len(listenerHostnames)+1)
+		if anyHostname {
+			matched = append(matched, routeHostname)
+		}
+		for _, listenerHostname := range listenerHostnames {
+			if hostname := intersectHostname(routeHostname, listenerHostname); hostname != "" {
+				matched = append(matched, hostname)
+			}
+		}
+		for _, hostname := range matched {
+			if !containsString(hostnames, hostname) {
+				hostnames = append(hostnames, hostname)
+			}
+		}
+	}
+	return hostnames
+}
+
+// intersectHostname returns the most specific hostname matched by both a route and a listener hostname, either of which
+// may be a wildcard such as *.example.com, or an empty string if they do not intersect
+func intersectHostname(routeHostname string, listenerHostname string) string {
+	switch {
+	case routeHostname == listenerHostname:
+		return routeHostname
+	case wildcardHostnameMatches(listenerHostname, routeHostname):
+		return routeHostname
+	case wildcardHostnameMatches(routeHostname, listenerHostname):
+		return listenerHostname
+	}
+	return ""
+}
+
+// wildcardHostnameMatches determines if the wildcard pattern, such as *.example.com, matches hostname.  The wildcard
+// stands for one or more leading labels, so *.example.com matches a.b.example.com and *.b.example.com but not
+// example.com.
+func wildcardHostnameMatches(pattern string, hostname string) bool {
+	if !strings.HasPrefix(pattern, "*.") {
+		return false
+	}
+	suffix := strings.TrimPrefix(pattern, "*")
+	return len(hostname) > len(suffix) && strings.HasSuffix(hostname, suffix)
+}
+
+// listenerAllowsRoute determines if the allowedRoutes of listener, on a Gateway in gatewayNamespace, admit an HTTPRoute
+// in routeNamespace.  By default only routes in the namespace of the Gateway are allowed.
+func (r *HTTPRouteReconciler) listenerAllowsRoute(ctx context.Context, listener gatewayListener, gatewayNamespace string, routeNamespace string) (bool, error) {
+	allowed := allowedRoutes{}
+	if listener.AllowedRoutes != nil {
+		allowed = *listener.AllowedRoutes
+	}
+
+	if len(allowed.Kinds) > 0 {
+		found := false
+		for _, kind := range allowed.Kinds {
+			if kind.Kind == "HTTPRoute" && (kind.Group == nil || *kind.Group == GatewayGroup) {
+				found = true
+			}
+		}
+		if !found {
+			return false, nil
+		}
+	}
+
+	from := "Same"
+	if allowed.Namespaces != nil && allowed.Namespaces.From != nil {
+		from = *allowed.Namespaces.From
+	}
+	switch from {
+	case "All":
+		return true, nil
+	case "Same":
+		return routeNamespace == gatewayNamespace, nil
+	case "Selector":
+		if allowed.Namespaces.Selector == nil {
+			return false, nil
+		}
+		selector, err := metav1.LabelSelectorAsSelector(allowed.Namespaces.Selector)
+		if err != nil {
+			logger.Info("ignoring listener with invalid namespace selector", "listener", listener.Name, "error", err.Error())
+			return false, nil
+		}
+		namespaceLabels, err := r.namespaceLabels(ctx, routeNamespace)
+		if err != nil {
+			return false, err
+		}
+		return selector.Matches(namespaceLabels), nil
+	}
+	return false, nil
+}
+
+// handlesGateway determines if the GatewayClass of gateway names the controllerName of the HTTPRouteReconciler
+func (r *HTTPRouteReconciler) handlesGateway(ctx context.Context, gateway *unstructured.Unstructured) (bool, error) {
+	className, _, _ := unstructured.NestedString(gateway.Object, "spec", "gatewayClassName")
+	if className == "" {
+		return false, nil
+	}
+
+	gatewayClass := NewGatewayObject(r.version, "GatewayClass")
+	if err := r.Get(ctx, types.NamespacedName{Name: className}, gatewayClass); err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("could not get gateway class %s: %w", className, err)
+	}
+
+	controllerName, _, _ := unstructured.NestedString(gatewayClass.Object, "spec", "controllerName")
+	return controllerName == r.controllerName, nil
+}
+
+// httpRouteCondition returns a condition observing the current generation of route
+func httpRouteCondition(route client.Object, conditionType string, status metav1.ConditionStatus, reason string, message string) metav1.Condition {
+	return metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: route.GetGeneration(),
+	}
+}
+
+// updateHTTPRouteStatus sets conditions on the status of route for each of parents, and marks each of rejected as not
+// accepted for its reason.  The status is written only when it changes, and the status written by other controllers
+// for other parents is kept.
+func (r *HTTPRouteReconciler) updateHTTPRouteStatus(ctx context.Context, route *unstructured.Unstructured, parents []parentReference, rejected []rejectedParent, conditions ...metav1.Condition) error {
+	existing, _, _ := unstructured.NestedSlice(route.Object, "status", "parents")
+	statuses := make([]routeParentStatus, 0, len(existing))
+	for _, s := range existing {
+		statusObj, ok := s.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		status := routeParentStatus{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(statusObj, &status); err != nil {
+			continue
+		}
+		statuses = append(statuses, status)
+	}
+	original := make([]routeParentStatus, len(statuses))
+	copy(original, statuses)
+
+	updated := make([]routeParentStatus, 0, len(statuses)+len(parents)+len(rejected))
+	for _, status := range statuses {
+		if status.ControllerName != r.controllerName {
+			updated = append(updated, status)
+		}
+	}
+	updated = r.appendParentStatuses(updated, statuses, parents, conditions)
+	for _, parent := range rejected {
+		updated = r.appendParentStatuses(updated, statuses, []parentReference{parent.ref}, []metav1.Condition{
+			httpRouteCondition(route, conditionAccepted, metav1.ConditionFalse, parent.reason, parent.message),
+		})
+	}
+
+	if reflect.DeepEqual(original, updated) {
+		return nil
+	}
+
+	parentsObj := make([]interface{}, 0, len(updated))
+	for i := range updated {
+		statusObj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&updated[i])
+		if err != nil {
+			return fmt.Errorf("could not serialize status of http route %s/%s: %w", route.GetNamespace(), route.GetName(), err)
+		}
+		parentsObj = append(parentsObj, statusObj)
+	}
+	if err := unstructured.SetNestedSlice(route.Object, parentsObj, "status", "parents"); err != nil {
+		return fmt.Errorf("could not set status of http route %s/%s: %w", route.GetNamespace(), route.GetName(), err)
+	}
+
+	if err := r.Status().Update(ctx, route); err != nil {
+		return fmt.Errorf("could not update status of http route %s/%s: %w", route.GetNamespace(), route.GetName(), err)
+	}
+	return nil
+}
+
+// appendParentStatuses appends a status with conditions for each of parents to updated, keeping the other conditions
+// previously set by the HTTPRouteReconciler in statuses
+func (r *HTTPRouteReconciler) appendParentStatuses(updated []routeParentStatus, statuses []routeParentStatus, parents []parentReference, conditions []metav1.Condition) []routeParentStatus {
+	for _, parent := range parents {
+		status := routeParentStatus{ParentRef: parent, ControllerName: r.controllerName}
+		for _, previous := range statuses {
+			if previous.ControllerName == r.controllerName && reflect.DeepEqual(previous.ParentRef, parent) {
+				status.Conditions = append(status.Conditions, previous.Conditions...)
+			}
+		}
+		for _, condition := range conditions {
+			meta.SetStatusCondition(&status.Conditions, condition)
+		}
+		updated = append(updated, status)
+	}
+	return updated
+}
+
+// requestsForHTTPRoutes returns requests for the HTTPRoutes in namespace selected by filter
+func (r *HTTPRouteReconciler) requestsForHTTPRoutes(namespace string, filter func(route *unstructured.Unstructured, spec httpRouteSpec) bool) []reconcile.Request {
+	requests := make([]reconcile.Request, 0)
+
+	routes := &unstructured.UnstructuredList{}
+	routes.SetAPIVersion(GatewayGroup + "/" + r.version)
+	routes.SetKind("HTTPRouteList")
+	if err := r.List(context.Background(), routes, client.InNamespace(namespace)); err != nil {
+		logger.Error(err, "could not list http routes", "namespace", namespace)
+		return requests
+	}
+
+	for i := range routes.Items {
+		spec := httpRouteSpec{}
+		if specObj, ok := routes.Items[i].Object["spec"].(map[string]interface{}); ok {
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(specObj, &spec); err != nil {
+				continue
+			}
+		}
+		if filter(&routes.Items[i], spec) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&routes.Items[i])})
+		}
+	}
+	return requests
+}
+
+// httpRouteReferencesService determines if spec has a backendRef referencing the Service name in the namespace of the
+// route
+func httpRouteReferencesService(spec httpRouteSpec, name string) bool {
+	for _, rule := range spec.Rules {
+		for _, ref := range rule.BackendRefs {
+			if ref.Name == name && ref.Namespace == nil && (ref.Kind == nil || *ref.Kind == "Service") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RequestsForGateway maps a Gateway onto requests for every HTTPRoute attached to it
+func (r *HTTPRouteReconciler) RequestsForGateway(obj client.Object) []reconcile.Request {
+	return r.requestsForHTTPRoutes("", func(route *unstructured.Unstructured, spec httpRouteSpec) bool {
+		for _, ref := range spec.ParentRefs {
+			if gatewayName, ok := parentGateway(ref, route.GetNamespace()); ok && gatewayName == client.ObjectKeyFromObject(obj) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// RequestsForGatewayClass maps a GatewayClass onto requests for every HTTPRoute, as Gateways may have started or
+// stopped using it
+func (r *HTTPRouteReconciler) RequestsForGatewayClass(client.Object) []reconcile.Request {
+	return r.requestsForHTTPRoutes("", func(*unstructured.Unstructured, httpRouteSpec) bool { return true })
+}
+
+// RequestsForService maps a Service onto requests for every HTTPRoute with a backendRef referencing it
+func (r *HTTPRouteReconciler) RequestsForService(obj client.Object) []reconcile.Request {
+	return r.requestsForHTTPRoutes(obj.GetNamespace(), func(_ *unstructured.Unstructured, spec httpRouteSpec) bool {
+		return httpRouteReferencesService(spec, obj.GetName())
+	})
+}
+
+// RequestsForNamespace maps a Namespace onto requests for every HTTPRoute within it
+func (r *HTTPRouteReconciler) RequestsForNamespace(obj client.Object) []reconcile.Request {
+	return r.requestsForHTTPRoutes(obj.GetName(), func(*unstructured.Unstructured, httpRouteSpec) bool { return true })
+}
+
+// RequestsForConfigMap maps the policy rules or host rules ConfigMap onto requests for every HTTPRoute
+func (r *HTTPRouteReconciler) RequestsForConfigMap(obj client.Object) []reconcile.Request {
+	key := client.ObjectKeyFromObject(obj)
+	if key != r.policyRules && key != r.hostRules {
+		return nil
+	}
+	return r.requestsForHTTPRoutes("", func(*unstructured.Unstructured, httpRouteSpec) bool { return true })
+}
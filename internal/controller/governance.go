@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"sort"
+
+	gyaml "github.com/ghodss/yaml"
+	"github.com/pomerium/pomerium-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// policyRule permits a governed policy option in Namespaces, optionally restricted to Values.  Namespaces are names or
+// glob patterns, and all namespaces are matched if none are listed.
+type policyRule struct {
+	Namespaces []string          `json:"namespaces,omitempty"`
+	Values     []json.RawMessage `json:"values,omitempty"`
+}
+
+// SetPolicyRules sets the ConfigMap holding policy rules.  Each key of the ConfigMap is a governed policy option and its
+// value a YAML or JSON list of rules permitting it, e.g. `[{"namespaces": ["public-*"], "values": [true]}]`.
+//
+// A governed option may only be set where a rule permits it.  Options without a key are not governed.
+func (r *Reconciler) SetPolicyRules(name types.NamespacedName) {
+	r.policyRules = name
+}
+
+// forbiddenPolicyOptions returns a description of each option in policyOptions the policy rules do not permit in
+// namespace.  policyOptions holds JSON option values.
+//
+// An error is returned if the policy rules cannot be read, so routes are never generated without them.
+func (r *Reconciler) forbiddenPolicyOptions(ctx context.Context, namespace string, policyOptions map[string]string) ([]string, error) {
+	if r.policyRules.Name == "" {
+		return nil, nil
+	}
+
+	configMap := &corev1.ConfigMap{}
+	if err := r.Get(ctx, r.policyRules, configMap); err != nil {
+		return nil, fmt.Errorf("could not get policy rules %s: %w", r.policyRules, err)
+	}
+
+	forbidden := make([]string, 0)
+	for option, value := range policyOptions {
+		rulesYAML, governed := configMap.Data[option]
+		if !governed {
+			continue
+		}
+
+		var rules []policyRule
+		if err := gyaml.Unmarshal([]byte(rulesYAML), &rules); err != nil {
+			return nil, validationError{fmt.Errorf("invalid policy rules for %s in %s: %w", option, r.policyRules, err)}
+		}
+
+		permitted, err := policyRulesPermit(rules, namespace, value)
+		if err != nil {
+			return nil, validationError{fmt.Errorf("invalid policy rules for %s in %s: %w", option, r.policyRules, err)}
+		}
+		if !permitted {
+			forbidden = append(forbidden, fmt.Sprintf("%s: %s is not permitted in namespace %s", option, value, namespace))
+		}
+	}
+
+	sort.Strings(forbidden)
+	return forbidden, nil
+}
+
+// policyRulesPermit determines if any of rules permits the JSON value in namespace
+func policyRulesPermit(rules []policyRule, namespace string, value string) (bool, error) {
+	var got interface{}
+	if err := json.Unmarshal([]byte(value), &got); err != nil {
+		return false, err
+	}
+
+	for _, rule := range rules {
+		namespaceMatch := len(rule.Namespaces) == 0
+		for _, pattern := range rule.Namespaces {
+			match, err := path.Match(pattern, namespace)
+			if err != nil {
+				return false, validationError{fmt.Errorf("invalid namespace pattern %q: %w", pattern, err)}
+			}
+			namespaceMatch = namespaceMatch || match
+		}
+		if !namespaceMatch {
+			continue
+		}
+
+		if len(rule.Values) == 0 {
+			return true, nil
+		}
+		for _, ruleValue := range rule.Values {
+			var want interface{}
+			if err := json.Unmarshal(ruleValue, &want); err != nil {
+				return false, err
+			}
+			if reflect.DeepEqual(want, got) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// RequestsForConfigMap maps the policy rules or host rules ConfigMap onto requests for every PomeriumRoute
+func (r *RouteReconciler) RequestsForConfigMap(obj client.Object) []reconcile.Request {
+	key := client.ObjectKeyFromObject(obj)
+	if key != r.policyRules && key != r.hostRules {
+		return nil
+	}
+	return r.requestsForRoutes("", func(*v1alpha1.PomeriumRoute) bool { return true })
+}
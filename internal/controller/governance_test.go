@@ -0,0 +1,182 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pomerium/pomerium-operator/api/v1alpha1"
+	"github.com/pomerium/pomerium-operator/internal/configmanager"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var testPolicyRulesName = types.NamespacedName{Name: "policy-rules", Namespace: "pomerium"}
+
+func newTestPolicyRules() *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: testPolicyRulesName.Name, Namespace: testPolicyRulesName.Namespace},
+		Data: map[string]string{
+			"allow_public_unauthenticated_access": "- namespaces: [public-*]\n",
+			"allow_any_authenticated_user":        "- values: [false]\n- namespaces: [corp]\n  values: [true]\n",
+		},
+	}
+}
+
+func Test_forbiddenPolicyOptions(t *testing.T) {
+	tests := []struct {
+		name          string
+		namespace     string
+		options       map[string]string
+		wantForbidden int
+	}{
+		{name: "ungoverned option", namespace: "test", options: map[string]string{"allowed_groups": `["foo"]`}},
+		{name: "permitted namespace", namespace: "public-docs", options: map[string]string{"allow_public_unauthenticated_access": `true`}},
+		{name: "forbidden namespace", namespace: "test", options: map[string]string{"allow_public_unauthenticated_access": `true`}, wantForbidden: 1},
+		{name: "permitted value", namespace: "test", options: map[string]string{"allow_any_authenticated_user": `false`}},
+		{name: "permitted value in namespace", namespace: "corp", options: map[string]string{"allow_any_authenticated_user": `true`}},
+		{name: "forbidden value", namespace: "test", options: map[string]string{"allow_any_authenticated_user": `true`}, wantForbidden: 1},
+		{
+			name:      "several forbidden",
+			namespace: "test",
+			options: map[string]string{
+				"allow_public_unauthenticated_access": `true`,
+				"allow_any_authenticated_user":        `true`,
+			},
+			wantForbidden: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewFakeClient(newTestPolicyRules())
+			r := NewReconciler(&corev1.Service{}, "pomerium", configmanager.NewConfigManager("test", "test", c, time.Nanosecond*1))
+			assert.NoError(t, r.InjectClient(c))
+			r.SetPolicyRules(testPolicyRulesName)
+
+			forbidden, err := r.forbiddenPolicyOptions(context.Background(), tt.namespace, tt.options)
+			assert.NoError(t, err)
+			assert.Len(t, forbidden, tt.wantForbidden, "forbidden: %v", forbidden)
+		})
+	}
+}
+
+func Test_forbiddenPolicyOptions_missingRules(t *testing.T) {
+	c := fake.NewFakeClient()
+	r := NewReconciler(&corev1.Service{}, "pomerium", configmanager.NewConfigManager("test", "test", c, time.Nanosecond*1))
+	assert.NoError(t, r.InjectClient(c))
+	r.SetPolicyRules(testPolicyRulesName)
+
+	_, err := r.forbiddenPolicyOptions(context.Background(), "test", map[string]string{"allowed_groups": `["foo"]`})
+	assert.Error(t, err)
+}
+
+func Test_Reconcile_missingPolicyRules(t *testing.T) {
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "service",
+			Namespace: "test",
+			Annotations: map[string]string{
+				"ingress.pomerium.io/from":           "https://test.lan.beyondcorp.org",
+				"ingress.pomerium.io/allowed_groups": `["foo"]`,
+			},
+		},
+		Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "https", Port: 443}}},
+	}
+
+	c := fake.NewFakeClient(service)
+	r := NewReconciler(&corev1.Service{}, "pomerium", configmanager.NewConfigManager("test", "test", c, time.Nanosecond*1))
+	assert.NoError(t, r.InjectClient(c))
+	r.SetPolicyRules(testPolicyRulesName)
+	recorder := record.NewFakeRecorder(10)
+	r.SetEventRecorder(recorder)
+
+	// The ConfigMap watch reconciles the Service again once the rules exist, so the failure is not retried
+	_, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: client.ObjectKeyFromObject(service)})
+	assert.NoError(t, err)
+
+	select {
+	case event := <-recorder.Events:
+		assert.True(t, strings.HasPrefix(event, "Warning InvalidPolicy"), "unexpected event %q", event)
+	default:
+		assert.Fail(t, "no event recorded")
+	}
+}
+
+func Test_Reconcile_forbiddenPolicy(t *testing.T) {
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "service",
+			Namespace: "test",
+			Annotations: map[string]string{
+				"ingress.pomerium.io/from":                                "https://test.lan.beyondcorp.org",
+				"ingress.pomerium.io/allow_public_unauthenticated_access": "true",
+			},
+		},
+		Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "https", Port: 443}}},
+	}
+
+	c := fake.NewFakeClient(service, newTestPolicyRules())
+	cm := configmanager.NewConfigManager("test", "test", c, time.Nanosecond*1)
+	r := NewReconciler(&corev1.Service{}, "pomerium", cm)
+	assert.NoError(t, r.InjectClient(c))
+	r.SetPolicyRules(testPolicyRulesName)
+	recorder := record.NewFakeRecorder(10)
+	r.SetEventRecorder(recorder)
+
+	_, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: client.ObjectKeyFromObject(service)})
+	assert.NoError(t, err)
+
+	select {
+	case event := <-recorder.Events:
+		assert.True(t, strings.HasPrefix(event, "Warning ForbiddenPolicy"), "unexpected event %q", event)
+	default:
+		assert.Fail(t, "no event recorded")
+	}
+
+	options, err := cm.GetCurrentConfig()
+	assert.NoError(t, err)
+	assert.Empty(t, options.Policies)
+
+	problems, err := r.ValidatePolicy(context.Background(), service)
+	assert.NoError(t, err)
+	assert.Len(t, problems, 1)
+
+	assert.Len(t, r.RequestsForConfigMap(newTestPolicyRules()), 1)
+}
+
+func Test_RouteReconciler_Reconcile_forbiddenPolicy(t *testing.T) {
+	route := newTestRoute("route", v1alpha1.PomeriumRouteSpec{
+		From:                             "https://app.lan.beyondcorp.org",
+		To:                               []v1alpha1.RouteBackend{newTestRouteBackend("backend", networkingv1.ServiceBackendPort{Number: 8080})},
+		AllowPublicUnauthenticatedAccess: true,
+	})
+
+	c := fake.NewFakeClient(route, newTestPolicyRules())
+	cm := configmanager.NewConfigManager("test", "test", c, time.Nanosecond*1)
+	r := NewRouteReconciler(cm)
+	assert.NoError(t, r.InjectClient(c))
+	r.SetPolicyRules(testPolicyRulesName)
+
+	_, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: client.ObjectKeyFromObject(route)})
+	assert.NoError(t, err)
+
+	got := &v1alpha1.PomeriumRoute{}
+	assert.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(route), got))
+	condition := meta.FindStatusCondition(got.Status.Conditions, v1alpha1.RouteConditionAccepted)
+	if assert.NotNil(t, condition) {
+		assert.Equal(t, metav1.ConditionFalse, condition.Status)
+		assert.Equal(t, reasonForbiddenPolicy, condition.Reason)
+	}
+
+	assert.Len(t, r.RequestsForConfigMap(newTestPolicyRules()), 1)
+}
@@ -0,0 +1,263 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	gyaml "github.com/ghodss/yaml"
+	"github.com/pomerium/pomerium-operator/internal/configmanager"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var deniedHostsGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "pomerium_operator_denied_hosts",
+	Help: "Number of hosts of a resource dropped because its namespace may not claim them",
+}, []string{"kind", "namespace", "name"})
+
+func init() {
+	metrics.Registry.MustRegister(deniedHostsGauge)
+}
+
+// hostRule reserves the hosts matching Hosts for the namespaces listed in Namespaces or selected by NamespaceSelector.
+// Hosts and Namespaces are names or glob patterns.
+type hostRule struct {
+	Hosts             []string              `json:"hosts"`
+	Namespaces        []string              `json:"namespaces,omitempty"`
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+}
+
+// SetHostRules sets the ConfigMap holding host rules.  Each key of the ConfigMap names a rule, and its value is a YAML
+// or JSON hostRule, e.g. `{"hosts": ["*.payroll.corp.example.com"], "namespaces": ["payroll"]}`.
+//
+// A host matched by any rule may only be routed from the namespaces of the rules matching it.  Hosts matched by no rule
+// may be routed from any namespace.
+func (r *Reconciler) SetHostRules(name types.NamespacedName) {
+	r.hostRules = name
+}
+
+// loadHostRules returns the host rules, ordered by name.  An error is returned if the host rules cannot be read, so
+// routes are never generated without them.
+func (r *Reconciler) loadHostRules(ctx context.Context) ([]hostRule, error) {
+	if r.hostRules.Name == "" {
+		return nil, nil
+	}
+
+	configMap := &corev1.ConfigMap{}
+	if err := r.Get(ctx, r.hostRules, configMap); err != nil {
+		return nil, fmt.Errorf("could not get host rules %s: %w", r.hostRules, err)
+	}
+
+	names := make([]string, 0, len(configMap.Data))
+	for name := range configMap.Data {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	rules := make([]hostRule, 0, len(names))
+	for _, name := range names {
+		rule := hostRule{}
+		if err := gyaml.Unmarshal([]byte(configMap.Data[name]), &rule); err != nil {
+			return nil, validationError{fmt.Errorf("invalid host rule %s in %s: %w", name, r.hostRules, err)}
+		}
+		for i := range rule.Hosts {
+			rule.Hosts[i] = normalizeHost(rule.Hosts[i])
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// hostPermitted determines if resources in namespace may route from, according to rules.  Hosts are compared in lower
+// case without a trailing dot.
+//
+// A wildcard host, e.g. `*.corp.example.com`, also claims every host reserved by a rule it overlaps, so it is only
+// permitted if namespace is admitted by a rule covering all of it, or by every rule it overlaps.  A from which cannot be
+// parsed is never permitted.
+func (r *Reconciler) hostPermitted(ctx context.Context, rules []hostRule, namespace string, from string) (bool, error) {
+	if len(rules) == 0 || from == "" {
+		return true, nil
+	}
+
+	fromURL, err := url.Parse(from)
+	if err != nil {
+		return false, nil
+	}
+	host := normalizeHost(fromURL.Hostname())
+
+	var namespaceLabels labels.Set
+	denied := false
+	for _, rule := range rules {
+		covered, err := globCovers(rule.Hosts, host)
+		if err != nil {
+			return false, err
+		}
+		overlapped := covered
+		if !covered && strings.ContainsAny(host, "*?[") {
+			overlapped = globsOverlap(rule.Hosts, host)
+		}
+		if !overlapped {
+			continue
+		}
+
+		admitted, err := r.hostRuleAdmits(ctx, rule, namespace, &namespaceLabels)
+		if err != nil {
+			return false, err
+		}
+		if covered && admitted {
+			return true, nil
+		}
+		if !admitted {
+			denied = true
+		}
+	}
+	return !denied, nil
+}
+
+// hostRuleAdmits determines if rule admits namespace.  The labels of namespace are looked up once into namespaceLabels.
+func (r *Reconciler) hostRuleAdmits(ctx context.Context, rule hostRule, namespace string, namespaceLabels *labels.Set) (bool, error) {
+	namespaceMatch, err := globMatch(rule.Namespaces, namespace)
+	if err != nil {
+		return false, err
+	}
+	if namespaceMatch {
+		return true, nil
+	}
+
+	if rule.NamespaceSelector == nil {
+		return false, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(rule.NamespaceSelector)
+	if err != nil {
+		return false, validationError{fmt.Errorf("invalid host rule namespace selector: %w", err)}
+	}
+	if *namespaceLabels == nil {
+		*namespaceLabels, err = r.namespaceLabels(ctx, namespace)
+		if err != nil {
+			return false, err
+		}
+	}
+	return selector.Matches(*namespaceLabels), nil
+}
+
+// normalizeHost returns host in lower case without a trailing dot
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// namespaceLabels returns the labels of namespace.  A namespace which does not exist has no labels.
+func (r *Reconciler) namespaceLabels(ctx context.Context, namespace string) (labels.Set, error) {
+	namespaceObj := &corev1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: namespace}, namespaceObj); err != nil {
+		if apierrors.IsNotFound(err) {
+			return labels.Set{}, nil
+		}
+		return nil, fmt.Errorf("could not get namespace %s: %w", namespace, err)
+	}
+	return labels.Set(namespaceObj.Labels), nil
+}
+
+// globCovers determines if every value matching host matches any of patterns.  A host without wildcards is simply
+// matched; a wildcard host is covered by patterns matching it literally whose own wildcards are all `*`.
+func globCovers(patterns []string, host string) (bool, error) {
+	if !strings.ContainsAny(host, "*?[") {
+		return globMatch(patterns, host)
+	}
+	for _, pattern := range patterns {
+		if strings.ContainsAny(pattern, "?[\\") {
+			continue
+		}
+		match, err := path.Match(pattern, host)
+		if err != nil {
+			return false, validationError{fmt.Errorf("invalid pattern %q: %w", pattern, err)}
+		}
+		if match {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// globsOverlap determines if some value matches both pattern and any of patterns.  Patterns with character classes are
+// only compared against each other literally.
+func globsOverlap(patterns []string, pattern string) bool {
+	for _, p := range patterns {
+		if strings.ContainsAny(p+pattern, "[\\") {
+			if match, _ := path.Match(pattern, p); match {
+				return true
+			}
+			continue
+		}
+		if globOverlap(p, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// globOverlap determines if some value matches both a and b, which may hold `*` and `?` wildcards
+func globOverlap(a string, b string) bool {
+	switch {
+	case a == "" || b == "":
+		return strings.Trim(a, "*") == "" && strings.Trim(b, "*") == ""
+	case a[0] == '*':
+		return globOverlap(a[1:], b) || globOverlap(a, b[1:])
+	case b[0] == '*':
+		return globOverlap(a, b[1:]) || globOverlap(a[1:], b)
+	case a[0] == '?' || b[0] == '?' || a[0] == b[0]:
+		return globOverlap(a[1:], b[1:])
+	}
+	return false
+}
+
+// globMatch determines if value matches any of patterns
+func globMatch(patterns []string, value string) (bool, error) {
+	for _, pattern := range patterns {
+		match, err := path.Match(pattern, value)
+		if err != nil {
+			return false, validationError{fmt.Errorf("invalid pattern %q: %w", pattern, err)}
+		}
+		if match {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// setDeniedHosts reports the number of hosts of obj dropped by the host rules.  Nothing is reported while validating.
+func (r *Reconciler) setDeniedHosts(obj runtime.Object, denied int) {
+	if r.validating {
+		return
+	}
+
+	metaObj, ok := obj.(metav1.Object)
+	if !ok {
+		return
+	}
+	gvk, err := apiutil.GVKForObject(obj, r.scheme)
+	if err != nil {
+		return
+	}
+
+	if denied == 0 {
+		deniedHostsGauge.DeleteLabelValues(gvk.Kind, metaObj.GetNamespace(), metaObj.GetName())
+		return
+	}
+	deniedHostsGauge.WithLabelValues(gvk.Kind, metaObj.GetNamespace(), metaObj.GetName()).Set(float64(denied))
+}
+
+// clearDeniedHosts removes the denied hosts reported for resource
+func clearDeniedHosts(resource configmanager.ResourceIdentifier) {
+	deniedHostsGauge.DeleteLabelValues(resource.GVK.Kind, resource.NamespacedName.Namespace, resource.NamespacedName.Name)
+}
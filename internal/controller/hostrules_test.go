@@ -0,0 +1,158 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pomerium/pomerium-operator/internal/configmanager"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var testHostRulesName = types.NamespacedName{Name: "host-rules", Namespace: "pomerium"}
+
+func newTestHostRules() *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: testHostRulesName.Name, Namespace: testHostRulesName.Namespace},
+		Data: map[string]string{
+			"payroll": "hosts: [payroll.corp.example.com, '*.payroll.corp.example.com']\nnamespaces: [payroll]\n",
+			"finance": "hosts: ['*.payroll.corp.example.com']\nnamespaceSelector:\n  matchLabels:\n    team: finance\n",
+		},
+	}
+}
+
+func Test_hostPermitted(t *testing.T) {
+	tests := []struct {
+		name      string
+		namespace string
+		from      string
+		want      bool
+	}{
+		{name: "ungoverned host", namespace: "sandbox", from: "https://sandbox.corp.example.com", want: true},
+		{name: "owning namespace", namespace: "payroll", from: "https://payroll.corp.example.com", want: true},
+		{name: "other namespace", namespace: "sandbox", from: "https://payroll.corp.example.com", want: false},
+		{name: "glob", namespace: "sandbox", from: "https://api.payroll.corp.example.com", want: false},
+		{name: "selected namespace", namespace: "finance", from: "https://api.payroll.corp.example.com", want: true},
+		{name: "selected namespace other host", namespace: "finance", from: "https://payroll.corp.example.com", want: false},
+		{name: "empty from", namespace: "sandbox", from: "", want: true},
+		{name: "upper case host", namespace: "sandbox", from: "https://PAYROLL.Corp.Example.com", want: false},
+		{name: "trailing dot", namespace: "sandbox", from: "https://payroll.corp.example.com.", want: false},
+		{name: "unparsable from", namespace: "sandbox", from: "https://payroll.corp.example.com/%zz", want: false},
+		{name: "wildcard overlapping reserved host", namespace: "sandbox", from: "https://*.corp.example.com", want: false},
+		{name: "wildcard overlapping reserved glob", namespace: "sandbox", from: "https://*.example.com", want: false},
+		{name: "wildcard partly admitted", namespace: "finance", from: "https://*.corp.example.com", want: false},
+		{name: "wildcard covered by owning rule", namespace: "payroll", from: "https://*.payroll.corp.example.com", want: true},
+		{name: "wildcard ungoverned", namespace: "sandbox", from: "https://*.sandbox.example.org", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewFakeClient(
+				newTestHostRules(),
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "finance", Labels: map[string]string{"team": "finance"}}},
+			)
+			r := NewReconciler(&corev1.Service{}, "pomerium", configmanager.NewConfigManager("test", "test", c, time.Nanosecond*1))
+			assert.NoError(t, r.InjectClient(c))
+			r.SetHostRules(testHostRulesName)
+
+			rules, err := r.loadHostRules(context.Background())
+			assert.NoError(t, err)
+			got, err := r.hostPermitted(context.Background(), rules, tt.namespace, tt.from)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_globOverlap(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"payroll.corp.example.com", "*.corp.example.com", true},
+		{"*.payroll.corp.example.com", "*.corp.example.com", true},
+		{"*.payroll.corp.example.com", "*.example.org", false},
+		{"a*.example.com", "*b.example.com", true},
+		{"?.example.com", "ab.example.com", false},
+		{"*", "anything", true},
+		{"", "*", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+" "+tt.b, func(t *testing.T) {
+			assert.Equal(t, tt.want, globOverlap(tt.a, tt.b))
+			assert.Equal(t, tt.want, globOverlap(tt.b, tt.a))
+		})
+	}
+}
+
+func Test_Reconcile_forbiddenHost(t *testing.T) {
+	pathType := networkingv1.PathTypePrefix
+	rule := func(host string) networkingv1.IngressRule {
+		return networkingv1.IngressRule{
+			Host: host,
+			IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{
+				Paths: []networkingv1.HTTPIngressPath{{
+					Path:     "/",
+					PathType: &pathType,
+					Backend: networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{
+						Name: "backend",
+						Port: networkingv1.ServiceBackendPort{Number: 80},
+					}},
+				}},
+			}},
+		}
+	}
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "hijack",
+			Namespace:   "sandbox",
+			Annotations: map[string]string{"ingress.pomerium.io/allowed_groups": `["foo"]`},
+		},
+		Spec: networkingv1.IngressSpec{Rules: []networkingv1.IngressRule{
+			rule("payroll.corp.example.com"),
+			rule("sandbox.corp.example.com"),
+		}},
+	}
+
+	c := fake.NewFakeClient(ingress, newTestHostRules())
+	cm := configmanager.NewConfigManager("test", "test", c, time.Nanosecond*1)
+	r := NewReconciler(&networkingv1.Ingress{}, "pomerium", cm)
+	assert.NoError(t, r.InjectClient(c))
+	r.SetHostRules(testHostRulesName)
+	recorder := record.NewFakeRecorder(10)
+	r.SetEventRecorder(recorder)
+
+	request := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(ingress)}
+	_, err := r.Reconcile(context.Background(), request)
+	assert.NoError(t, err)
+
+	select {
+	case event := <-recorder.Events:
+		assert.True(t, strings.HasPrefix(event, "Warning ForbiddenHost"), "unexpected event %q", event)
+	default:
+		assert.Fail(t, "no event recorded")
+	}
+
+	options, err := cm.GetCurrentConfig()
+	assert.NoError(t, err)
+	if assert.Len(t, options.Policies, 1) {
+		assert.Equal(t, "https://sandbox.corp.example.com", options.Policies[0].From)
+	}
+	assert.Equal(t, float64(1), testutil.ToFloat64(deniedHostsGauge.WithLabelValues("Ingress", "sandbox", "hijack")))
+
+	assert.NoError(t, c.Delete(context.Background(), ingress))
+	_, err = r.Reconcile(context.Background(), request)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, testutil.CollectAndCount(deniedHostsGauge))
+}
@@ -0,0 +1,505 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/pomerium/pomerium-operator/internal/configmanager"
+	pomeriumconfig "github.com/pomerium/pomerium/config"
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// GatewayGroup is the API group of the Gateway API
+const GatewayGroup = "gateway.networking.k8s.io"
+
+// GatewayVersions are the Gateway API versions HTTPRoutes can be read with, most preferred first
+var GatewayVersions = []string{"v1", "v1beta1", "v1alpha2"}
+
+// HTTPRoute condition types and reasons from the Gateway API
+const (
+	conditionAccepted      = "Accepted"
+	conditionResolvedRefs  = "ResolvedRefs"
+	reasonAccepted         = "Accepted"
+	reasonUnsupportedValue = "UnsupportedValue"
+	reasonNoHostnames      = "NoMatchingListenerHostname"
+	reasonBackendNotFound  = "BackendNotFound"
+	reasonInvalidKind      = "InvalidKind"
+	reasonRefNotPermitted  = "RefNotPermitted"
+	reasonResolvedRefs     = "ResolvedRefs"
+
+	reasonNotAllowedByListeners = "NotAllowedByListeners"
+)
+
+// The parts of the Gateway API HTTPRoute spec translated into Pomerium policies.  The Gateway API types are read from
+// unstructured objects, so no particular Gateway API release is required.
+type httpRouteSpec struct {
+	ParentRefs []parentReference `json:"parentRefs,omitempty"`
+	Hostnames  []string          `json:"hostnames,omitempty"`
+	Rules      []httpRouteRule   `json:"rules,omitempty"`
+}
+
+type parentReference struct {
+	Group       *string `json:"group,omitempty"`
+	Kind        *string `json:"kind,omitempty"`
+	Namespace   *string `json:"namespace,omitempty"`
+	Name        string  `json:"name"`
+	SectionName *string `json:"sectionName,omitempty"`
+	Port        *int32  `json:"port,omitempty"`
+}
+
+type httpRouteRule struct {
+	Matches     []httpRouteMatch  `json:"matches,omitempty"`
+	Filters     []httpRouteFilter `json:"filters,omitempty"`
+	BackendRefs []httpBackendRef  `json:"backendRefs,omitempty"`
+}
+
+type httpRouteMatch struct {
+	Path        *httpPathMatch           `json:"path,omitempty"`
+	Headers     []map[string]interface{} `json:"headers,omitempty"`
+	QueryParams []map[string]interface{} `json:"queryParams,omitempty"`
+	Method      *string                  `json:"method,omitempty"`
+}
+
+type httpPathMatch struct {
+	Type  *string `json:"type,omitempty"`
+	Value *string `json:"value,omitempty"`
+}
+
+type httpRouteFilter struct {
+	Type                  string                `json:"type"`
+	RequestHeaderModifier *httpHeaderFilter     `json:"requestHeaderModifier,omitempty"`
+	URLRewrite            *httpURLRewriteFilter `json:"urlRewrite,omitempty"`
+}
+
+type httpHeaderFilter struct {
+	Set    []httpHeader `json:"set,omitempty"`
+	Add    []httpHeader `json:"add,omitempty"`
+	Remove []string     `json:"remove,omitempty"`
+}
+
+type httpHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type httpURLRewriteFilter struct {
+	Hostname *string           `json:"hostname,omitempty"`
+	Path     *httpPathModifier `json:"path,omitempty"`
+}
+
+type httpPathModifier struct {
+	Type               string  `json:"type"`
+	ReplaceFullPath    *string `json:"replaceFullPath,omitempty"`
+	ReplacePrefixMatch *string `json:"replacePrefixMatch,omitempty"`
+}
+
+type httpBackendRef struct {
+	Group     *string           `json:"group,omitempty"`
+	Kind      *string           `json:"kind,omitempty"`
+	Name      string            `json:"name"`
+	Namespace *string           `json:"namespace,omitempty"`
+	Port      *int32            `json:"port,omitempty"`
+	Weight    *int32            `json:"weight,omitempty"`
+	Filters   []httpRouteFilter `json:"filters,omitempty"`
+}
+
+// HTTPRouteReconciler implements a Kubernetes reconciler for Gateway API HTTPRoutes attached to a Gateway whose
+// GatewayClass names the controllerName of the HTTPRouteReconciler.  Use NewHTTPRouteReconciler() to initialize.
+//
+// Namespace, address, rule and event settings are shared with Reconciler.
+type HTTPRouteReconciler struct {
+	*Reconciler
+	version        string
+	controllerName string
+}
+
+// NewHTTPRouteReconciler returns a new HTTPRouteReconciler reading Gateway API version objects and claiming
+// GatewayClasses with spec.controllerName controllerName
+func NewHTTPRouteReconciler(version string, controllerName string, configManager *configmanager.ConfigManager) *HTTPRouteReconciler {
+	return &HTTPRouteReconciler{
+		Reconciler:     NewReconciler(NewGatewayObject(version, "HTTPRoute"), "", configManager),
+		version:        version,
+		controllerName: controllerName,
+	}
+}
+
+// NewGatewayObject returns an empty unstructured Gateway API object of kind at version
+func NewGatewayObject(version string, kind string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(GatewayGroup + "/" + version)
+	obj.SetKind(kind)
+	return obj
+}
+
+// httpRouteResourceIdentifier returns the ResourceIdentifier an HTTPRoute's policies are stored under
+func (r *HTTPRouteReconciler) httpRouteResourceIdentifier(name types.NamespacedName) configmanager.ResourceIdentifier {
+	return configmanager.ResourceIdentifier{
+		GVK:            NewGatewayObject(r.version, "HTTPRoute").GroupVersionKind(),
+		NamespacedName: name,
+	}
+}
+
+// Reconcile implements the Reconciler interface for HTTPRoutes.  The Accepted and ResolvedRefs conditions of each
+// parent Gateway handled by the HTTPRouteReconciler report the result.
+//
+// Routes which cannot be translated are removed.  If a backend cannot be resolved the existing route is left in place
+// and the request is retried.
+func (r *HTTPRouteReconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	logger.V(1).Info("notified of change to http route", "resource", req.NamespacedName)
+	resource := r.httpRouteResourceIdentifier(req.NamespacedName)
+
+	route := NewGatewayObject(r.version, "HTTPRoute")
+	if err := r.Get(ctx, req.NamespacedName, route); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return reconcile.Result{}, fmt.Errorf("could not get http route %s: %w", req.NamespacedName, err)
+		}
+
+		logger.V(1).Info("http route deleted", "resource", resource)
+		r.RemoveRoute(resource)
+		clearDeniedHosts(resource)
+		return reconcile.Result{}, nil
+	}
+
+	match, err := r.namespaceMatch(ctx, route.GetNamespace())
+	if err != nil {
+		return reconcile.Result{}, fmt.Errorf("could not determine if namespace of %s is watched: %w", req.NamespacedName, err)
+	}
+	if !match {
+		logger.V(1).Info("http route is not in a watched namespace", "resource", resource)
+		r.RemoveRoute(resource)
+		return reconcile.Result{}, nil
+	}
+
+	spec := httpRouteSpec{}
+	if specObj, ok := route.Object["spec"].(map[string]interface{}); ok {
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(specObj, &spec); err != nil {
+			logger.Error(err, "could not read http route spec", "resource", resource)
+			r.RemoveRoute(resource)
+			r.event(route, corev1.EventTypeWarning, reasonInvalidPolicy, "could not read spec: %s", err)
+			return reconcile.Result{}, nil
+		}
+	}
+
+	parents, rejected, hostnames, err := r.acceptedParents(ctx, route, spec)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if len(parents) == 0 {
+		logger.V(1).Info("http route is not attached to a pomerium gateway", "resource", resource)
+		r.RemoveRoute(resource)
+		return reconcile.Result{}, r.updateHTTPRouteStatus(ctx, route, nil, rejected)
+	}
+
+	policies, reason, err := r.policiesFromHTTPRoute(ctx, route, spec, hostnames)
+	var routeMeta configmanager.RouteMeta
+	if err == nil {
+		var metaErr error
+		if routeMeta, metaErr = routeMetaFromObj(route); metaErr != nil {
+			reason, err = reasonInvalidAnnotation, fmt.Errorf("invalid %s annotation: %w", priorityAnnotation, metaErr)
+		}
+	}
+
+	switch {
+	case err == nil:
+		if r.configManager.SetWithMeta(resource, policies, routeMeta) {
+			r.event(route, corev1.EventTypeNormal, reasonRouteAccepted, "accepted %d pomerium route(s)", len(policies))
+		}
+		return reconcile.Result{}, r.updateHTTPRouteStatus(ctx, route, parents, rejected,
+			httpRouteCondition(route, conditionAccepted, metav1.ConditionTrue, reasonAccepted, "route accepted"),
+			httpRouteCondition(route, conditionResolvedRefs, metav1.ConditionTrue, reasonResolvedRefs, "all references resolved"))
+	case reason == reasonUnresolvableBackend:
+		r.event(route, corev1.EventTypeWarning, reason, "%s", err)
+		if statusErr := r.updateHTTPRouteStatus(ctx, route, parents, rejected,
+			httpRouteCondition(route, conditionAccepted, metav1.ConditionTrue, reasonAccepted, "route accepted"),
+			httpRouteCondition(route, conditionResolvedRefs, metav1.ConditionFalse, reasonBackendNotFound, err.Error())); statusErr != nil {
+			return reconcile.Result{}, statusErr
+		}
+		return reconcile.Result{}, fmt.Errorf("could not generate policy from %s: %w", req.NamespacedName, err)
+	case reason == "":
+		return reconcile.Result{}, fmt.Errorf("could not generate policy from %s: %w", req.NamespacedName, err)
+	case reason == reasonInvalidKind || reason == reasonRefNotPermitted:
+		r.RemoveRoute(resource)
+		r.event(route, corev1.EventTypeWarning, reasonInvalidPolicy, "%s", err)
+		return reconcile.Result{}, r.updateHTTPRouteStatus(ctx, route, parents, rejected,
+			httpRouteCondition(route, conditionAccepted, metav1.ConditionTrue, reasonAccepted, "route accepted"),
+			httpRouteCondition(route, conditionResolvedRefs, metav1.ConditionFalse, reason, err.Error()))
+	default:
+		r.RemoveRoute(resource)
+		r.event(route, corev1.EventTypeWarning, reason, "%s", err)
+		return reconcile.Result{}, r.updateHTTPRouteStatus(ctx, route, parents, rejected,
+			httpRouteCondition(route, conditionAccepted, metav1.ConditionFalse, reason, err.Error()),
+			httpRouteCondition(route, conditionResolvedRefs, metav1.ConditionTrue, reasonResolvedRefs, "all references resolved"))
+	}
+}
+
+// policiesFromHTTPRoute returns the pomerium Policies described by route, with one policy for each of hostnames and
+// each match of each rule.  hostnames are the hostnames of route served by the listeners of its parent Gateways.
+//
+// Policy options are read from the `ingress.pomerium.io/*` annotations of route.  On failure, the event reason
+// describing the problem is returned with the error, or an empty reason if the route should be retried unchanged.
+func (r *HTTPRouteReconciler) policiesFromHTTPRoute(ctx context.Context, route *unstructured.Unstructured, spec httpRouteSpec, hostnames []string) ([]pomeriumconfig.Policy, string, error) {
+	if len(hostnames) == 0 {
+		return nil, reasonNoHostnames, fmt.Errorf("route and its parent listeners have no hostnames")
+	}
+
+	annotations := make(map[string]string)
+	for k, v := range route.GetAnnotations() {
+		if strings.HasPrefix(k, policyAnnotationPrefix) {
+			annotations[k] = v
+		}
+	}
+	options, ok, err := r.annotationPolicyOptions(route, annotations)
+	if err != nil {
+		return nil, "", err
+	}
+	if !ok {
+		return nil, reasonInvalidAnnotation, fmt.Errorf("route rejected: invalid pomerium annotations")
+	}
+
+	// Filters set options too, so policy rules are checked against the options of each rule after merging
+	ruleOptions := make([]map[string]string, len(spec.Rules))
+	for i, rule := range spec.Rules {
+		filterOptions, err := httpRouteFilterOptions(rule.Filters)
+		if err != nil {
+			return nil, reasonUnsupportedValue, fmt.Errorf("rule %d: %w", i, err)
+		}
+		for k, v := range options {
+			if _, set := filterOptions[k]; !set {
+				filterOptions[k] = v
+			}
+		}
+
+		forbidden, err := r.forbiddenPolicyOptions(ctx, route.GetNamespace(), filterOptions)
+		if err != nil {
+			return nil, "", err
+		}
+		if len(forbidden) > 0 {
+			return nil, reasonForbiddenPolicy, fmt.Errorf("rule %d: policy rejected by policy rules: %s", i, strings.Join(forbidden, "; "))
+		}
+		ruleOptions[i] = filterOptions
+	}
+
+	hostRules, err := r.loadHostRules(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	permittedHostnames := make([]string, 0, len(hostnames))
+	for _, hostname := range hostnames {
+		permitted, err := r.hostPermitted(ctx, hostRules, route.GetNamespace(), "https://"+hostname)
+		if err != nil {
+			return nil, "", err
+		}
+		if !permitted {
+			r.event(route, corev1.EventTypeWarning, reasonForbiddenHost, "dropping hostname %q: host is not permitted in namespace %s", hostname, route.GetNamespace())
+			continue
+		}
+		permittedHostnames = append(permittedHostnames, hostname)
+	}
+	r.setDeniedHosts(route, len(hostnames)-len(permittedHostnames))
+	if len(permittedHostnames) == 0 {
+		return nil, reasonForbiddenHost, fmt.Errorf("no hostname of the route is permitted in namespace %s", route.GetNamespace())
+	}
+
+	strategy, err := r.addressStrategyFor(route)
+	if err != nil {
+		return nil, reasonInvalidAnnotation, fmt.Errorf("invalid %s annotation: %w", addressStrategyAnnotation, err)
+	}
+	upstreamHost, err := upstreamHostFor(route)
+	if err != nil {
+		return nil, reasonInvalidAnnotation, fmt.Errorf("invalid %s annotation: %w", upstreamHostAnnotation, err)
+	}
+
+	scheme, ok := route.GetAnnotations()["pomerium.ingress.kubernetes.io/backend-protocol"]
+	if !ok {
+		scheme = "http"
+	}
+	scheme = strings.ToLower(scheme)
+
+	policies := make([]pomeriumconfig.Policy, 0)
+	for i, rule := range spec.Rules {
+		optionsJSON, err := policyOptionsJSON(ruleOptions[i])
+		if err != nil {
+			return nil, reasonInvalidPolicy, fmt.Errorf("rule %d: %w", i, err)
+		}
+
+		upstream, reason, err := r.httpRouteUpstream(route.GetNamespace(), rule.BackendRefs, strategy)
+		if err != nil {
+			return nil, reason, fmt.Errorf("rule %d: %w", i, err)
+		}
+		if upstreamHost != "" {
+			upstream = withUpstreamHost(upstream, upstreamHost)
+		}
+
+		matches := rule.Matches
+		if len(matches) == 0 {
+			matches = []httpRouteMatch{{}}
+		}
+		for _, hostname := range permittedHostnames {
+			for _, match := range matches {
+				policy := pomeriumconfig.Policy{From: "https://" + hostname}
+				if err := setHTTPRouteMatch(&policy, match); err != nil {
+					return nil, reasonUnsupportedValue, fmt.Errorf("rule %d: %w", i, err)
+				}
+				if err := yaml.Unmarshal(optionsJSON, &policy); err != nil {
+					return nil, reasonInvalidPolicy, fmt.Errorf("rule %d: could not apply options to policy: %w", i, err)
+				}
+				policy = policyTo(policy, scheme, upstream)
+				if err := policy.Validate(); err != nil {
+					return nil, reasonInvalidPolicy, fmt.Errorf("rule %d: invalid policy: %w", i, err)
+				}
+				policies = append(policies, policy)
+			}
+		}
+	}
+	if len(policies) == 0 {
+		return nil, reasonInvalidPolicy, fmt.Errorf("route has no rules")
+	}
+
+	// Pomerium uses the first matching policy, so more specific paths must come first
+	sort.SliceStable(policies, func(i, j int) bool {
+		iLen, iRank := pathSpecificity(policies[i])
+		jLen, jRank := pathSpecificity(policies[j])
+		if iLen != jLen {
+			return iLen > jLen
+		}
+		return iRank > jRank
+	})
+	return policies, "", nil
+}
+
+// setHTTPRouteMatch maps the path of match onto the path matching fields of policy.  Pomerium policies cannot match
+// headers, query parameters or methods, so such matches are rejected.
+func setHTTPRouteMatch(policy *pomeriumconfig.Policy, match httpRouteMatch) error {
+	switch {
+	case len(match.Headers) > 0:
+		return errors.New("header matches are not supported")
+	case len(match.QueryParams) > 0:
+		return errors.New("query parameter matches are not supported")
+	case match.Method != nil:
+		return errors.New("method matches are not supported")
+	case match.Path == nil:
+		return nil
+	}
+
+	pathType, value := "PathPrefix", "/"
+	if match.Path.Type != nil {
+		pathType = *match.Path.Type
+	}
+	if match.Path.Value != nil {
+		value = *match.Path.Value
+	}
+
+	switch pathType {
+	case "Exact":
+		policy.Path = value
+	case "PathPrefix":
+		if value != "/" {
+			policy.Regex = prefixPathRegex(value)
+		}
+	case "RegularExpression":
+		policy.Regex = value
+	default:
+		return fmt.Errorf("path match type %q is not supported", pathType)
+	}
+	return nil
+}
+
+// httpRouteFilterOptions returns the JSON policy option values implementing filters.  Request header modifiers and URL
+// rewrites of the hostname or path prefix are supported.
+func httpRouteFilterOptions(filters []httpRouteFilter) (map[string]string, error) {
+	options := make(map[string]interface{})
+	for _, filter := range filters {
+		switch {
+		case filter.Type == "RequestHeaderModifier" && filter.RequestHeaderModifier != nil:
+			headers := make(map[string]string)
+			for _, header := range append(filter.RequestHeaderModifier.Add, filter.RequestHeaderModifier.Set...) {
+				headers[header.Name] = header.Value
+			}
+			if len(headers) > 0 {
+				options["set_request_headers"] = headers
+			}
+			if len(filter.RequestHeaderModifier.Remove) > 0 {
+				options["remove_request_headers"] = filter.RequestHeaderModifier.Remove
+			}
+		case filter.Type == "URLRewrite" && filter.URLRewrite != nil:
+			if filter.URLRewrite.Hostname != nil {
+				options["host_rewrite"] = *filter.URLRewrite.Hostname
+			}
+			if path := filter.URLRewrite.Path; path != nil {
+				if path.Type != "ReplacePrefixMatch" || path.ReplacePrefixMatch == nil {
+					return nil, fmt.Errorf("URL rewrite path type %q is not supported", path.Type)
+				}
+				options["prefix_rewrite"] = *path.ReplacePrefixMatch
+			}
+		default:
+			return nil, fmt.Errorf("filter type %q is not supported", filter.Type)
+		}
+	}
+
+	values := make(map[string]string, len(options))
+	for k, v := range options {
+		valueJSON, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		values[k] = string(valueJSON)
+	}
+	return values, nil
+}
+
+// policyOptionsJSON returns policyOptions, holding JSON option values, as a JSON object
+func policyOptionsJSON(policyOptions map[string]string) ([]byte, error) {
+	values := make(map[string]json.RawMessage, len(policyOptions))
+	for k, v := range policyOptions {
+		values[k] = json.RawMessage(v)
+	}
+	return json.Marshal(values)
+}
+
+// httpRouteUpstream resolves the Service backendRef of a rule in namespace into an upstream URL.  The `to` of a
+// Pomerium policy is a single URL, so rules with more than one backendRef are rejected.  On failure, the reason
+// describing the problem is returned with the error.
+func (r *Reconciler) httpRouteUpstream(namespace string, backendRefs []httpBackendRef, strategy AddressStrategy) (url.URL, string, error) {
+	switch {
+	case len(backendRefs) == 0:
+		return url.URL{}, reasonInvalidPolicy, fmt.Errorf("rule has no backends")
+	case len(backendRefs) > 1:
+		return url.URL{}, reasonUnsupportedValue, fmt.Errorf("rule has %d backends, only a single backend is supported", len(backendRefs))
+	}
+
+	ref := backendRefs[0]
+	if (ref.Group != nil && *ref.Group != "") || (ref.Kind != nil && *ref.Kind != "Service") {
+		return url.URL{}, reasonInvalidKind, fmt.Errorf("backend %s is not a Service", ref.Name)
+	}
+	if ref.Namespace != nil && *ref.Namespace != namespace {
+		return url.URL{}, reasonRefNotPermitted, fmt.Errorf("backend %s/%s is in another namespace", *ref.Namespace, ref.Name)
+	}
+	if len(ref.Filters) > 0 {
+		return url.URL{}, reasonUnsupportedValue, fmt.Errorf("backend %s: backend filters are not supported", ref.Name)
+	}
+	if ref.Port == nil {
+		return url.URL{}, reasonInvalidPolicy, fmt.Errorf("backend %s has no port", ref.Name)
+	}
+	if ref.Weight != nil && *ref.Weight == 0 {
+		return url.URL{}, reasonInvalidPolicy, fmt.Errorf("backend %s receives no traffic", ref.Name)
+	}
+
+	backendURL, err := r.serviceToURL(ref.Name, intstr.FromInt(int(*ref.Port)), namespace, strategy)
+	if err != nil {
+		return url.URL{}, reasonUnresolvableBackend, fmt.Errorf("could not resolve backend %s: %w", ref.Name, err)
+	}
+	return backendURL, "", nil
+}
@@ -0,0 +1,525 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pomerium/pomerium-operator/internal/configmanager"
+	pomeriumconfig "github.com/pomerium/pomerium/config"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const testGatewayVersion = "v1"
+
+func init() {
+	for _, kind := range []string{"Gateway", "GatewayClass", "HTTPRoute"} {
+		gvk := NewGatewayObject(testGatewayVersion, kind).GroupVersionKind()
+		scheme.Scheme.AddKnownTypeWithName(gvk, &unstructured.Unstructured{})
+		scheme.Scheme.AddKnownTypeWithName(gvk.GroupVersion().WithKind(kind+"List"), &unstructured.UnstructuredList{})
+	}
+}
+
+func newTestGatewayObject(kind string, name string, namespace string, spec map[string]interface{}) *unstructured.Unstructured {
+	obj := NewGatewayObject(testGatewayVersion, kind)
+	obj.SetName(name)
+	obj.SetNamespace(namespace)
+	obj.SetGeneration(1)
+	obj.Object["spec"] = spec
+	return obj
+}
+
+func newTestHTTPRoute(rules ...interface{}) *unstructured.Unstructured {
+	route := newTestGatewayObject("HTTPRoute", "route", "test", map[string]interface{}{
+		"parentRefs": []interface{}{
+			map[string]interface{}{"name": "pomerium"},
+			map[string]interface{}{"name": "other"},
+		},
+		"rules": rules,
+	})
+	route.SetAnnotations(map[string]string{"ingress.pomerium.io/allowed_groups": `["foo"]`})
+	return route
+}
+
+func testHTTPRouteParentStatus(t *testing.T, route *unstructured.Unstructured) []routeParentStatus {
+	parents, _, _ := unstructured.NestedSlice(route.Object, "status", "parents")
+	statuses := make([]routeParentStatus, 0, len(parents))
+	for _, p := range parents {
+		status := routeParentStatus{}
+		assert.NoError(t, runtime.DefaultUnstructuredConverter.FromUnstructured(p.(map[string]interface{}), &status))
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+func Test_HTTPRouteReconciler_Reconcile(t *testing.T) {
+	gatewayObjs := []runtime.Object{
+		newTestGatewayObject("GatewayClass", "pomerium", "", map[string]interface{}{"controllerName": "pomerium.io/gateway-controller"}),
+		newTestGatewayObject("GatewayClass", "other", "", map[string]interface{}{"controllerName": "example.com/other"}),
+		newTestGatewayObject("Gateway", "pomerium", "test", map[string]interface{}{
+			"gatewayClassName": "pomerium",
+			"listeners":        []interface{}{map[string]interface{}{"name": "https", "hostname": "app.lan.beyondcorp.org"}},
+		}),
+		newTestGatewayObject("Gateway", "other", "test", map[string]interface{}{"gatewayClassName": "other"}),
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "test"}, Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{{Port: 80}}}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "b", Namespace: "test"}, Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{{Port: 80}}}},
+	}
+
+	tests := []struct {
+		name         string
+		route        *unstructured.Unstructured
+		wantPolicies int
+		wantAccepted metav1.ConditionStatus
+		wantReason   string
+		wantResolved metav1.ConditionStatus
+		wantErr      bool
+	}{
+		{
+			name: "accepted",
+			route: newTestHTTPRoute(map[string]interface{}{
+				"matches":     []interface{}{map[string]interface{}{"path": map[string]interface{}{"type": "PathPrefix", "value": "/app"}}},
+				"backendRefs": []interface{}{map[string]interface{}{"name": "a", "port": int64(80), "weight": int64(2)}},
+			}),
+			wantPolicies: 1,
+			wantAccepted: metav1.ConditionTrue,
+			wantReason:   reasonAccepted,
+			wantResolved: metav1.ConditionTrue,
+		},
+		{
+			name: "multiple backends",
+			route: newTestHTTPRoute(map[string]interface{}{
+				"backendRefs": []interface{}{
+					map[string]interface{}{"name": "a", "port": int64(80)},
+					map[string]interface{}{"name": "b", "port": int64(80)},
+				},
+			}),
+			wantAccepted: metav1.ConditionFalse,
+			wantReason:   reasonUnsupportedValue,
+			wantResolved: metav1.ConditionTrue,
+		},
+		{
+			name: "unsupported header match",
+			route: newTestHTTPRoute(map[string]interface{}{
+				"matches":     []interface{}{map[string]interface{}{"headers": []interface{}{map[string]interface{}{"name": "x-test", "value": "1"}}}},
+				"backendRefs": []interface{}{map[string]interface{}{"name": "a", "port": int64(80)}},
+			}),
+			wantAccepted: metav1.ConditionFalse,
+			wantReason:   reasonUnsupportedValue,
+			wantResolved: metav1.ConditionTrue,
+		},
+		{
+			name: "backend in another namespace",
+			route: newTestHTTPRoute(map[string]interface{}{
+				"backendRefs": []interface{}{map[string]interface{}{"name": "a", "namespace": "other", "port": int64(80)}},
+			}),
+			wantAccepted: metav1.ConditionTrue,
+			wantReason:   reasonAccepted,
+			wantResolved: metav1.ConditionFalse,
+		},
+		{
+			name: "unresolvable backend",
+			route: newTestHTTPRoute(map[string]interface{}{
+				"backendRefs": []interface{}{map[string]interface{}{"name": "missing", "port": int64(80)}},
+			}),
+			wantAccepted: metav1.ConditionTrue,
+			wantReason:   reasonAccepted,
+			wantResolved: metav1.ConditionFalse,
+			wantErr:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			otherStatus := map[string]interface{}{
+				"parentRef":      map[string]interface{}{"name": "other"},
+				"controllerName": "example.com/other",
+				"conditions":     []interface{}{},
+			}
+			assert.NoError(t, unstructured.SetNestedSlice(tt.route.Object, []interface{}{otherStatus}, "status", "parents"))
+
+			c := fake.NewFakeClient(append(gatewayObjs, tt.route)...)
+			cm := configmanager.NewConfigManager("test", "test", c, time.Nanosecond*1)
+			r := NewHTTPRouteReconciler(testGatewayVersion, "pomerium.io/gateway-controller", cm)
+			assert.NoError(t, r.InjectClient(c))
+
+			request := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(tt.route)}
+			_, err := r.Reconcile(context.Background(), request)
+			assert.Equal(t, tt.wantErr, err != nil)
+
+			options, err := cm.GetCurrentConfig()
+			assert.NoError(t, err)
+			assert.Len(t, options.Policies, tt.wantPolicies)
+
+			route := NewGatewayObject(testGatewayVersion, "HTTPRoute")
+			assert.NoError(t, c.Get(context.Background(), request.NamespacedName, route))
+			statuses := testHTTPRouteParentStatus(t, route)
+			if assert.Len(t, statuses, 2) {
+				assert.Equal(t, "example.com/other", statuses[0].ControllerName)
+				assert.Equal(t, "pomerium", statuses[1].ParentRef.Name)
+				assert.Equal(t, "pomerium.io/gateway-controller", statuses[1].ControllerName)
+
+				accepted := meta.FindStatusCondition(statuses[1].Conditions, conditionAccepted)
+				if assert.NotNil(t, accepted) {
+					assert.Equal(t, tt.wantAccepted, accepted.Status)
+					assert.Equal(t, tt.wantReason, accepted.Reason)
+					assert.Equal(t, int64(1), accepted.ObservedGeneration)
+				}
+				resolved := meta.FindStatusCondition(statuses[1].Conditions, conditionResolvedRefs)
+				if assert.NotNil(t, resolved) {
+					assert.Equal(t, tt.wantResolved, resolved.Status)
+				}
+			}
+
+			assert.NoError(t, c.Delete(context.Background(), route))
+			_, err = r.Reconcile(context.Background(), request)
+			assert.NoError(t, err)
+
+			options, err = cm.GetCurrentConfig()
+			assert.NoError(t, err)
+			assert.Empty(t, options.Policies)
+		})
+	}
+}
+
+func Test_HTTPRouteReconciler_allowedRoutes(t *testing.T) {
+	selector := map[string]interface{}{
+		"from":     "Selector",
+		"selector": map[string]interface{}{"matchLabels": map[string]interface{}{"gateway": "pomerium"}},
+	}
+
+	tests := []struct {
+		name           string
+		allowedRoutes  map[string]interface{}
+		routeNamespace string
+		wantAccepted   bool
+	}{
+		{name: "same namespace by default", routeNamespace: "test", wantAccepted: true},
+		{name: "other namespace by default", routeNamespace: "other", wantAccepted: false},
+		{name: "all namespaces", allowedRoutes: map[string]interface{}{"namespaces": map[string]interface{}{"from": "All"}}, routeNamespace: "other", wantAccepted: true},
+		{name: "selected namespace", allowedRoutes: map[string]interface{}{"namespaces": selector}, routeNamespace: "selected", wantAccepted: true},
+		{name: "unselected namespace", allowedRoutes: map[string]interface{}{"namespaces": selector}, routeNamespace: "other", wantAccepted: false},
+		{name: "http route kind", allowedRoutes: map[string]interface{}{"kinds": []interface{}{map[string]interface{}{"kind": "HTTPRoute"}}}, routeNamespace: "test", wantAccepted: true},
+		{name: "other kind", allowedRoutes: map[string]interface{}{"kinds": []interface{}{map[string]interface{}{"kind": "TCPRoute"}}}, routeNamespace: "test", wantAccepted: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listener := map[string]interface{}{"name": "https", "hostname": "app.lan.beyondcorp.org"}
+			if tt.allowedRoutes != nil {
+				listener["allowedRoutes"] = tt.allowedRoutes
+			}
+			route := newTestGatewayObject("HTTPRoute", "route", tt.routeNamespace, map[string]interface{}{
+				"parentRefs": []interface{}{map[string]interface{}{"name": "pomerium", "namespace": "test"}},
+				"rules":      []interface{}{map[string]interface{}{"backendRefs": []interface{}{map[string]interface{}{"name": "a", "port": int64(80)}}}},
+			})
+			route.SetAnnotations(map[string]string{"ingress.pomerium.io/allowed_groups": `["foo"]`})
+
+			c := fake.NewFakeClient(
+				newTestGatewayObject("GatewayClass", "pomerium", "", map[string]interface{}{"controllerName": "pomerium.io/gateway-controller"}),
+				newTestGatewayObject("Gateway", "pomerium", "test", map[string]interface{}{
+					"gatewayClassName": "pomerium",
+					"listeners":        []interface{}{listener},
+				}),
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "selected", Labels: map[string]string{"gateway": "pomerium"}}},
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "other"}},
+				&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: tt.routeNamespace}, Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{{Port: 80}}}},
+				route,
+			)
+			cm := configmanager.NewConfigManager("test", "test", c, time.Nanosecond*1)
+			r := NewHTTPRouteReconciler(testGatewayVersion, "pomerium.io/gateway-controller", cm)
+			assert.NoError(t, r.InjectClient(c))
+
+			request := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(route)}
+			_, err := r.Reconcile(context.Background(), request)
+			assert.NoError(t, err)
+
+			options, err := cm.GetCurrentConfig()
+			assert.NoError(t, err)
+			if tt.wantAccepted {
+				assert.Len(t, options.Policies, 1)
+			} else {
+				assert.Empty(t, options.Policies)
+			}
+
+			updated := NewGatewayObject(testGatewayVersion, "HTTPRoute")
+			assert.NoError(t, c.Get(context.Background(), request.NamespacedName, updated))
+			statuses := testHTTPRouteParentStatus(t, updated)
+			if assert.Len(t, statuses, 1) {
+				accepted := meta.FindStatusCondition(statuses[0].Conditions, conditionAccepted)
+				if assert.NotNil(t, accepted) {
+					if tt.wantAccepted {
+						assert.Equal(t, metav1.ConditionTrue, accepted.Status)
+					} else {
+						assert.Equal(t, metav1.ConditionFalse, accepted.Status)
+						assert.Equal(t, reasonNotAllowedByListeners, accepted.Reason)
+					}
+				}
+			}
+		})
+	}
+}
+
+func Test_HTTPRouteReconciler_listenerHostnames(t *testing.T) {
+	tests := []struct {
+		name             string
+		listenerHostname string
+		routeHostnames   []interface{}
+		wantFrom         []string
+		wantReason       string
+	}{
+		{"listener hostname by default", "app.lan.beyondcorp.org", nil, []string{"https://app.lan.beyondcorp.org"}, reasonAccepted},
+		{"matching hostname", "app.lan.beyondcorp.org", []interface{}{"app.lan.beyondcorp.org", "other.lan.beyondcorp.org"}, []string{"https://app.lan.beyondcorp.org"}, reasonAccepted},
+		{"wildcard listener", "*.lan.beyondcorp.org", []interface{}{"app.lan.beyondcorp.org", "beyondcorp.org"}, []string{"https://app.lan.beyondcorp.org"}, reasonAccepted},
+		{"wildcard route", "app.lan.beyondcorp.org", []interface{}{"*.beyondcorp.org"}, []string{"https://app.lan.beyondcorp.org"}, reasonAccepted},
+		{"listener without hostname", "", []interface{}{"app.lan.beyondcorp.org"}, []string{"https://app.lan.beyondcorp.org"}, reasonAccepted},
+		{"no matching hostname", "app.lan.beyondcorp.org", []interface{}{"other.lan.beyondcorp.org"}, nil, reasonNoHostnames},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listener := map[string]interface{}{"name": "https"}
+			if tt.listenerHostname != "" {
+				listener["hostname"] = tt.listenerHostname
+			}
+			route := newTestGatewayObject("HTTPRoute", "route", "test", map[string]interface{}{
+				"parentRefs": []interface{}{map[string]interface{}{"name": "pomerium"}},
+				"rules":      []interface{}{map[string]interface{}{"backendRefs": []interface{}{map[string]interface{}{"name": "a", "port": int64(80)}}}},
+			})
+			if tt.routeHostnames != nil {
+				assert.NoError(t, unstructured.SetNestedSlice(route.Object, tt.routeHostnames, "spec", "hostnames"))
+			}
+			route.SetAnnotations(map[string]string{"ingress.pomerium.io/allowed_groups": `["foo"]`})
+
+			c := fake.NewFakeClient(
+				newTestGatewayObject("GatewayClass", "pomerium", "", map[string]interface{}{"controllerName": "pomerium.io/gateway-controller"}),
+				newTestGatewayObject("Gateway", "pomerium", "test", map[string]interface{}{
+					"gatewayClassName": "pomerium",
+					"listeners":        []interface{}{listener},
+				}),
+				&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "test"}, Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{{Port: 80}}}},
+				route,
+			)
+			cm := configmanager.NewConfigManager("test", "test", c, time.Nanosecond*1)
+			r := NewHTTPRouteReconciler(testGatewayVersion, "pomerium.io/gateway-controller", cm)
+			assert.NoError(t, r.InjectClient(c))
+
+			request := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(route)}
+			_, err := r.Reconcile(context.Background(), request)
+			assert.NoError(t, err)
+
+			options, err := cm.GetCurrentConfig()
+			assert.NoError(t, err)
+			from := make([]string, 0, len(options.Policies))
+			for _, policy := range options.Policies {
+				from = append(from, policy.From)
+			}
+			assert.ElementsMatch(t, tt.wantFrom, from)
+
+			updated := NewGatewayObject(testGatewayVersion, "HTTPRoute")
+			assert.NoError(t, c.Get(context.Background(), request.NamespacedName, updated))
+			statuses := testHTTPRouteParentStatus(t, updated)
+			if assert.Len(t, statuses, 1) {
+				accepted := meta.FindStatusCondition(statuses[0].Conditions, conditionAccepted)
+				if assert.NotNil(t, accepted) {
+					assert.Equal(t, tt.wantReason, accepted.Reason)
+				}
+			}
+		})
+	}
+}
+
+func Test_intersectHostname(t *testing.T) {
+	tests := []struct {
+		routeHostname    string
+		listenerHostname string
+		want             string
+	}{
+		{"app.example.com", "app.example.com", "app.example.com"},
+		{"app.example.com", "other.example.com", ""},
+		{"app.example.com", "*.example.com", "app.example.com"},
+		{"a.b.example.com", "*.example.com", "a.b.example.com"},
+		{"example.com", "*.example.com", ""},
+		{"*.example.com", "app.example.com", "app.example.com"},
+		{"*.example.com", "*.b.example.com", "*.b.example.com"},
+		{"*.b.example.com", "*.example.com", "*.b.example.com"},
+		{"*.example.com", "*.example.org", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.routeHostname+" "+tt.listenerHostname, func(t *testing.T) {
+			assert.Equal(t, tt.want, intersectHostname(tt.routeHostname, tt.listenerHostname))
+		})
+	}
+}
+
+func Test_HTTPRouteReconciler_policiesFromHTTPRoute(t *testing.T) {
+	c := fake.NewFakeClient(
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "test"}, Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{{Port: 80}}}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "b", Namespace: "test"}, Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{{Port: 80}}}},
+	)
+	r := NewHTTPRouteReconciler(testGatewayVersion, "pomerium.io/gateway-controller", configmanager.NewConfigManager("test", "test", c, time.Nanosecond*1))
+	assert.NoError(t, r.InjectClient(c))
+
+	route := newTestHTTPRoute()
+	port := int32(80)
+	hostnames := []string{"a.lan.beyondcorp.org", "b.lan.beyondcorp.org"}
+	spec := httpRouteSpec{
+		Rules: []httpRouteRule{{
+			BackendRefs: []httpBackendRef{{Name: "a", Port: &port}},
+			Filters: []httpRouteFilter{{
+				Type:                  "RequestHeaderModifier",
+				RequestHeaderModifier: &httpHeaderFilter{Set: []httpHeader{{Name: "X-Test", Value: "1"}}},
+			}},
+		}},
+	}
+
+	policies, reason, err := r.policiesFromHTTPRoute(context.Background(), route, spec, hostnames)
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	if assert.Len(t, policies, 2) {
+		assert.Equal(t, "https://a.lan.beyondcorp.org", policies[0].From)
+		assert.Equal(t, "https://b.lan.beyondcorp.org", policies[1].From)
+		assert.Equal(t, []string{"foo"}, policies[0].AllowedGroups)
+		assert.Equal(t, map[string]string{"X-Test": "1"}, policies[0].SetRequestHeaders)
+
+		policyBytes, err := yaml.Marshal(policies[0])
+		assert.NoError(t, err)
+		assert.Contains(t, string(policyBytes), "http://a.test.svc.cluster.local:80")
+		assert.NotContains(t, string(policyBytes), "b.test.svc.cluster.local")
+	}
+
+	_, reason, err = r.policiesFromHTTPRoute(context.Background(), route, spec, nil)
+	assert.Error(t, err)
+	assert.Equal(t, reasonNoHostnames, reason)
+
+	weight := int32(0)
+	spec.Rules[0].BackendRefs = []httpBackendRef{{Name: "a", Port: &port}, {Name: "b", Port: &port, Weight: &weight}}
+	_, reason, err = r.policiesFromHTTPRoute(context.Background(), route, spec, hostnames)
+	assert.Error(t, err)
+	assert.Equal(t, reasonUnsupportedValue, reason)
+
+	spec.Rules[0].BackendRefs = []httpBackendRef{{Name: "a", Port: &port, Weight: &weight}}
+	_, reason, err = r.policiesFromHTTPRoute(context.Background(), route, spec, hostnames)
+	assert.Error(t, err)
+	assert.Equal(t, reasonInvalidPolicy, reason)
+}
+
+func Test_HTTPRouteReconciler_policiesFromHTTPRoute_forbiddenFilterOptions(t *testing.T) {
+	policyRules := newTestPolicyRules()
+	policyRules.Data["host_rewrite"] = "- namespaces: [trusted]\n"
+	c := fake.NewFakeClient(
+		policyRules,
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "test"}, Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{{Port: 80}}}},
+	)
+	r := NewHTTPRouteReconciler(testGatewayVersion, "pomerium.io/gateway-controller", configmanager.NewConfigManager("test", "test", c, time.Nanosecond*1))
+	assert.NoError(t, r.InjectClient(c))
+	r.SetPolicyRules(testPolicyRulesName)
+
+	route := newTestHTTPRoute()
+	host := "internal.lan.beyondcorp.org"
+	port := int32(80)
+	hostnames := []string{"a.lan.beyondcorp.org"}
+	spec := httpRouteSpec{
+		Rules: []httpRouteRule{{
+			BackendRefs: []httpBackendRef{{Name: "a", Port: &port}},
+			Filters:     []httpRouteFilter{{Type: "URLRewrite", URLRewrite: &httpURLRewriteFilter{Hostname: &host}}},
+		}},
+	}
+
+	_, reason, err := r.policiesFromHTTPRoute(context.Background(), route, spec, hostnames)
+	assert.Error(t, err)
+	assert.Equal(t, reasonForbiddenPolicy, reason)
+
+	spec.Rules[0].Filters = nil
+	policies, reason, err := r.policiesFromHTTPRoute(context.Background(), route, spec, hostnames)
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Len(t, policies, 1)
+}
+
+func Test_setHTTPRouteMatch(t *testing.T) {
+	str := func(s string) *string { return &s }
+
+	tests := []struct {
+		name       string
+		match      httpRouteMatch
+		wantPolicy pomeriumconfig.Policy
+		wantErr    bool
+	}{
+		{"no path", httpRouteMatch{}, pomeriumconfig.Policy{}, false},
+		{"root prefix", httpRouteMatch{Path: &httpPathMatch{Type: str("PathPrefix"), Value: str("/")}}, pomeriumconfig.Policy{}, false},
+		{"prefix", httpRouteMatch{Path: &httpPathMatch{Value: str("/app")}}, pomeriumconfig.Policy{Regex: "^/app(/.*)?$"}, false},
+		{"exact", httpRouteMatch{Path: &httpPathMatch{Type: str("Exact"), Value: str("/app")}}, pomeriumconfig.Policy{Path: "/app"}, false},
+		{"regex", httpRouteMatch{Path: &httpPathMatch{Type: str("RegularExpression"), Value: str("^/a.*")}}, pomeriumconfig.Policy{Regex: "^/a.*"}, false},
+		{"unknown path type", httpRouteMatch{Path: &httpPathMatch{Type: str("Glob"), Value: str("/*")}}, pomeriumconfig.Policy{}, true},
+		{"method", httpRouteMatch{Method: str("GET")}, pomeriumconfig.Policy{}, true},
+		{"query", httpRouteMatch{QueryParams: []map[string]interface{}{{"name": "q"}}}, pomeriumconfig.Policy{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := pomeriumconfig.Policy{}
+			err := setHTTPRouteMatch(&policy, tt.match)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.wantPolicy, policy)
+		})
+	}
+}
+
+func Test_httpRouteFilterOptions(t *testing.T) {
+	host := "internal.lan.beyondcorp.org"
+	prefix := "/"
+
+	options, err := httpRouteFilterOptions([]httpRouteFilter{
+		{Type: "RequestHeaderModifier", RequestHeaderModifier: &httpHeaderFilter{
+			Add:    []httpHeader{{Name: "X-Add", Value: "a"}},
+			Remove: []string{"X-Remove"},
+		}},
+		{Type: "URLRewrite", URLRewrite: &httpURLRewriteFilter{
+			Hostname: &host,
+			Path:     &httpPathModifier{Type: "ReplacePrefixMatch", ReplacePrefixMatch: &prefix},
+		}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"set_request_headers":    `{"X-Add":"a"}`,
+		"remove_request_headers": `["X-Remove"]`,
+		"host_rewrite":           `"internal.lan.beyondcorp.org"`,
+		"prefix_rewrite":         `"/"`,
+	}, options)
+
+	_, err = httpRouteFilterOptions([]httpRouteFilter{{Type: "RequestMirror"}})
+	assert.Error(t, err)
+}
+
+func Test_HTTPRouteReconciler_RequestsForGateway(t *testing.T) {
+	otherRoute := newTestGatewayObject("HTTPRoute", "other", "test", map[string]interface{}{
+		"parentRefs": []interface{}{map[string]interface{}{"name": "other"}},
+		"rules":      []interface{}{map[string]interface{}{"backendRefs": []interface{}{map[string]interface{}{"name": "b"}}}},
+	})
+	c := fake.NewFakeClient(newTestHTTPRoute(map[string]interface{}{
+		"backendRefs": []interface{}{map[string]interface{}{"name": "a"}},
+	}), otherRoute)
+	r := NewHTTPRouteReconciler(testGatewayVersion, "pomerium.io/gateway-controller", configmanager.NewConfigManager("test", "test", c, time.Nanosecond*1))
+	assert.NoError(t, r.InjectClient(c))
+
+	routeRequest := reconcile.Request{NamespacedName: types.NamespacedName{Name: "route", Namespace: "test"}}
+	assert.Equal(t, []reconcile.Request{routeRequest},
+		r.RequestsForGateway(newTestGatewayObject("Gateway", "pomerium", "test", nil)))
+	assert.Empty(t, r.RequestsForGateway(newTestGatewayObject("Gateway", "pomerium", "elsewhere", nil)))
+	assert.Equal(t, []reconcile.Request{routeRequest},
+		r.RequestsForService(&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "test"}}))
+	assert.Len(t, r.RequestsForGatewayClass(newTestGatewayObject("GatewayClass", "pomerium", "", nil)), 2)
+	assert.Len(t, r.RequestsForNamespace(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test"}}), 2)
+}
@@ -0,0 +1,202 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	networkingv1beta1 "k8s.io/api/extensions/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
+	ingressclassv1beta1 "k8s.io/api/networking/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// defaultIngressClassAnnotation marks an IngressClass as the class for Ingresses which do not name one
+const defaultIngressClassAnnotation = "ingressclass.kubernetes.io/is-default-class"
+
+// SetIngressController enables IngressClass handling for Ingress resources.  IngressClasses with a spec.controller
+// equal to controller are claimed by this Reconciler.  networking.k8s.io/v1 Ingresses are matched against
+// networking.k8s.io/v1 IngressClasses, and extensions/v1beta1 Ingresses against networking.k8s.io/v1beta1 IngressClasses.
+//
+// When unset, only the `kubernetes.io/ingress.class` annotation is considered.
+func (r *Reconciler) SetIngressController(controller string) {
+	r.ingressController = controller
+}
+
+// classMatch determines if obj belongs to this Reconciler.
+//
+// The `kubernetes.io/XXXXX.class` annotation takes precedence.  Otherwise Ingresses are matched by spec.ingressClassName,
+// or the default IngressClass if they do not name one.
+func (r *Reconciler) classMatch(ctx context.Context, obj client.Object) (bool, error) {
+	if _, exists := obj.GetAnnotations()[r.controllerAnnotation]; exists || r.ingressController == "" {
+		return r.ControllerClassMatch(obj), nil
+	}
+
+	if !isIngress(obj) {
+		return r.ControllerClassMatch(obj), nil
+	}
+
+	if className := ingressClassName(obj); className != nil {
+		return r.ingressClassMatch(ctx, *className)
+	}
+
+	return r.defaultIngressClassMatch(ctx)
+}
+
+// ingressClassMatch determines if the IngressClass name is claimed by this Reconciler
+func (r *Reconciler) ingressClassMatch(ctx context.Context, name string) (bool, error) {
+	ingressClass := r.newIngressClass()
+	if err := r.Get(ctx, types.NamespacedName{Name: name}, ingressClass); err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("could not get ingress class %s: %w", name, err)
+	}
+
+	return ingressClassController(ingressClass) == r.ingressController, nil
+}
+
+// defaultIngressClassMatch determines if a default IngressClass is claimed by this Reconciler
+func (r *Reconciler) defaultIngressClassMatch(ctx context.Context) (bool, error) {
+	ingressClasses, err := r.listIngressClasses(ctx)
+	if err != nil {
+		return false, fmt.Errorf("could not list ingress classes: %w", err)
+	}
+
+	for _, ingressClass := range ingressClasses {
+		if ingressClass.GetAnnotations()[defaultIngressClassAnnotation] != "true" {
+			continue
+		}
+		if ingressClassController(ingressClass) == r.ingressController {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// newIngressClass returns an empty IngressClass of the version served alongside the Reconciler's Ingress kind
+func (r *Reconciler) newIngressClass() client.Object {
+	if _, ok := r.kind.(*networkingv1beta1.Ingress); ok {
+		return &ingressclassv1beta1.IngressClass{}
+	}
+	return &networkingv1.IngressClass{}
+}
+
+// listIngressClasses returns all IngressClasses of the version served alongside the Reconciler's Ingress kind
+func (r *Reconciler) listIngressClasses(ctx context.Context) ([]client.Object, error) {
+	ingressClasses := make([]client.Object, 0)
+
+	switch r.newIngressClass().(type) {
+	case *ingressclassv1beta1.IngressClass:
+		list := &ingressclassv1beta1.IngressClassList{}
+		if err := r.List(ctx, list); err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			ingressClasses = append(ingressClasses, &list.Items[i])
+		}
+	default:
+		list := &networkingv1.IngressClassList{}
+		if err := r.List(ctx, list); err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			ingressClasses = append(ingressClasses, &list.Items[i])
+		}
+	}
+
+	return ingressClasses, nil
+}
+
+// ingressClassController returns spec.controller of an IngressClass of any supported version
+func ingressClassController(obj client.Object) string {
+	switch ingressClass := obj.(type) {
+	case *networkingv1.IngressClass:
+		return ingressClass.Spec.Controller
+	case *ingressclassv1beta1.IngressClass:
+		return ingressClass.Spec.Controller
+	}
+	return ""
+}
+
+// RequestsForIngressClass maps an IngressClass onto requests for every Ingress which names it or may fall back to it
+// as the default class.
+func (r *Reconciler) RequestsForIngressClass(obj client.Object) []reconcile.Request {
+	switch obj.(type) {
+	case *networkingv1.IngressClass, *ingressclassv1beta1.IngressClass:
+	default:
+		return nil
+	}
+
+	if ingressClassController(obj) == r.ingressController {
+		logger.V(1).Info("claimed ingress class", "class", obj.GetName())
+	}
+
+	ingresses, err := r.listIngresses(context.Background())
+	if err != nil {
+		logger.Error(err, "could not list ingresses for ingress class", "class", obj.GetName())
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0)
+	for _, ingress := range ingresses {
+		className := ingressClassName(ingress)
+		if className != nil && *className != obj.GetName() {
+			continue
+		}
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+			Name:      ingress.GetName(),
+			Namespace: ingress.GetNamespace(),
+		}})
+	}
+	return requests
+}
+
+// listIngresses returns all Ingresses of the Reconciler's kind
+func (r *Reconciler) listIngresses(ctx context.Context, opts ...client.ListOption) ([]client.Object, error) {
+	ingresses := make([]client.Object, 0)
+
+	switch r.kind.(type) {
+	case *networkingv1.Ingress:
+		list := &networkingv1.IngressList{}
+		if err := r.List(ctx, list, opts...); err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			ingresses = append(ingresses, &list.Items[i])
+		}
+	case *networkingv1beta1.Ingress:
+		list := &networkingv1beta1.IngressList{}
+		if err := r.List(ctx, list, opts...); err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			ingresses = append(ingresses, &list.Items[i])
+		}
+	}
+
+	return ingresses, nil
+}
+
+// isIngress determines if obj is an Ingress of any supported version
+func isIngress(obj client.Object) bool {
+	switch obj.(type) {
+	case *networkingv1.Ingress, *networkingv1beta1.Ingress:
+		return true
+	}
+	return false
+}
+
+// ingressClassName returns spec.ingressClassName of an Ingress, or nil if it is unset or obj is not an Ingress
+func ingressClassName(obj client.Object) *string {
+	switch ingress := obj.(type) {
+	case *networkingv1.Ingress:
+		return ingress.Spec.IngressClassName
+	case *networkingv1beta1.Ingress:
+		return ingress.Spec.IngressClassName
+	}
+	return nil
+}
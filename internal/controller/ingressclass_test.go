@@ -0,0 +1,197 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pomerium/pomerium-operator/internal/configmanager"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const testIngressController = "pomerium.io/ingress-controller"
+
+func newTestIngressClass(name string, controller string, isDefault bool) *networkingv1.IngressClass {
+	ingressClass := &networkingv1.IngressClass{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       networkingv1.IngressClassSpec{Controller: controller},
+	}
+	if isDefault {
+		ingressClass.Annotations = map[string]string{defaultIngressClassAnnotation: "true"}
+	}
+	return ingressClass
+}
+
+func newTestClassedIngress(name string, className string, annotations map[string]string) *networkingv1.Ingress {
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   "test",
+			Annotations: annotations,
+		},
+	}
+	if className != "" {
+		ingress.Spec.IngressClassName = &className
+	}
+	return ingress
+}
+
+func Test_Reconciler_classMatch(t *testing.T) {
+	tests := []struct {
+		name              string
+		ingressController string
+		fakeObjs          []runtime.Object
+		obj               client.Object
+		expectedMatch     bool
+	}{
+		{
+			name:          "ingress class handling disabled",
+			obj:           newTestClassedIngress("test", "nginx", nil),
+			fakeObjs:      []runtime.Object{newTestIngressClass("nginx", "k8s.io/ingress-nginx", true)},
+			expectedMatch: true,
+		},
+		{
+			name:              "annotation takes precedence",
+			ingressController: testIngressController,
+			obj:               newTestClassedIngress("test", "nginx", map[string]string{"kubernetes.io/ingress.class": "pomerium"}),
+			fakeObjs:          []runtime.Object{newTestIngressClass("nginx", "k8s.io/ingress-nginx", false)},
+			expectedMatch:     true,
+		},
+		{
+			name:              "claimed ingress class",
+			ingressController: testIngressController,
+			obj:               newTestClassedIngress("test", "pomerium", nil),
+			fakeObjs:          []runtime.Object{newTestIngressClass("pomerium", testIngressController, false)},
+			expectedMatch:     true,
+		},
+		{
+			name:              "other ingress class",
+			ingressController: testIngressController,
+			obj:               newTestClassedIngress("test", "nginx", nil),
+			fakeObjs: []runtime.Object{
+				newTestIngressClass("pomerium", testIngressController, true),
+				newTestIngressClass("nginx", "k8s.io/ingress-nginx", false),
+			},
+			expectedMatch: false,
+		},
+		{
+			name:              "missing ingress class",
+			ingressController: testIngressController,
+			obj:               newTestClassedIngress("test", "missing", nil),
+			fakeObjs:          []runtime.Object{newTestIngressClass("pomerium", testIngressController, true)},
+			expectedMatch:     false,
+		},
+		{
+			name:              "claimed default ingress class",
+			ingressController: testIngressController,
+			obj:               newTestClassedIngress("test", "", nil),
+			fakeObjs: []runtime.Object{
+				newTestIngressClass("pomerium", testIngressController, true),
+				newTestIngressClass("nginx", "k8s.io/ingress-nginx", false),
+			},
+			expectedMatch: true,
+		},
+		{
+			name:              "other default ingress class",
+			ingressController: testIngressController,
+			obj:               newTestClassedIngress("test", "", nil),
+			fakeObjs: []runtime.Object{
+				newTestIngressClass("pomerium", testIngressController, false),
+				newTestIngressClass("nginx", "k8s.io/ingress-nginx", true),
+			},
+			expectedMatch: false,
+		},
+		{
+			name:              "no default ingress class",
+			ingressController: testIngressController,
+			obj:               newTestClassedIngress("test", "", nil),
+			expectedMatch:     false,
+		},
+		{
+			name:              "service ignores ingress classes",
+			ingressController: testIngressController,
+			obj: &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test"},
+			},
+			expectedMatch: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewFakeClient(tt.fakeObjs...)
+			r := NewReconciler(tt.obj, "pomerium", configmanager.NewConfigManager("test", "test", c, time.Nanosecond*1))
+			assert.NoError(t, r.InjectClient(c))
+			r.SetIngressController(tt.ingressController)
+
+			match, err := r.classMatch(context.Background(), tt.obj)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedMatch, match)
+		})
+	}
+}
+
+func Test_Reconciler_RequestsForIngressClass(t *testing.T) {
+	c := fake.NewFakeClient(
+		newTestClassedIngress("named", "pomerium", nil),
+		newTestClassedIngress("unnamed", "", nil),
+		newTestClassedIngress("other", "nginx", nil),
+	)
+	r := NewReconciler(&networkingv1.Ingress{}, "pomerium", configmanager.NewConfigManager("test", "test", c, time.Nanosecond*1))
+	assert.NoError(t, r.InjectClient(c))
+	r.SetIngressController(testIngressController)
+
+	requests := r.RequestsForIngressClass(newTestIngressClass("pomerium", testIngressController, true))
+	assert.ElementsMatch(t, []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Name: "named", Namespace: "test"}},
+		{NamespacedName: types.NamespacedName{Name: "unnamed", Namespace: "test"}},
+	}, requests)
+}
+
+func Test_Reconciler_classMatch_v1beta1(t *testing.T) {
+	className := "pomerium"
+	named := &extensionsv1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "named", Namespace: "test"},
+		Spec:       extensionsv1beta1.IngressSpec{IngressClassName: &className},
+	}
+	unnamed := &extensionsv1beta1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "unnamed", Namespace: "test"}}
+	ingressClass := &networkingv1beta1.IngressClass{
+		ObjectMeta: metav1.ObjectMeta{Name: "pomerium"},
+		Spec:       networkingv1beta1.IngressClassSpec{Controller: testIngressController},
+	}
+
+	c := fake.NewFakeClient(named, unnamed, ingressClass)
+	r := NewReconciler(&extensionsv1beta1.Ingress{}, "pomerium", configmanager.NewConfigManager("test", "test", c, time.Nanosecond*1))
+	assert.NoError(t, r.InjectClient(c))
+	r.SetIngressController(testIngressController)
+
+	match, err := r.classMatch(context.Background(), named)
+	assert.NoError(t, err)
+	assert.True(t, match)
+
+	// Unannotated Ingresses without a class are not claimed unless a claimed class is the default
+	match, err = r.classMatch(context.Background(), unnamed)
+	assert.NoError(t, err)
+	assert.False(t, match)
+
+	ingressClass.Annotations = map[string]string{defaultIngressClassAnnotation: "true"}
+	assert.NoError(t, c.Update(context.Background(), ingressClass))
+	match, err = r.classMatch(context.Background(), unnamed)
+	assert.NoError(t, err)
+	assert.True(t, match)
+
+	assert.ElementsMatch(t, []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Name: "named", Namespace: "test"}},
+		{NamespacedName: types.NamespacedName{Name: "unnamed", Namespace: "test"}},
+	}, r.RequestsForIngressClass(ingressClass))
+}
@@ -0,0 +1,115 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// SetNamespaces restricts the Reconciler to resources in namespaces.  An empty list allows every namespace.
+func (r *Reconciler) SetNamespaces(namespaces []string) {
+	r.namespaces = namespaces
+}
+
+// SetNamespaceSelector restricts the Reconciler to resources in namespaces whose labels match selector.  A nil selector
+// allows every namespace.
+func (r *Reconciler) SetNamespaceSelector(selector labels.Selector) {
+	r.namespaceSelector = selector
+}
+
+// namespaceMatch determines if resources in namespace are handled by the Reconciler.  A namespace which does not exist
+// does not match a selector.
+func (r *Reconciler) namespaceMatch(ctx context.Context, namespace string) (bool, error) {
+	if len(r.namespaces) > 0 {
+		found := false
+		for _, allowed := range r.namespaces {
+			if allowed == namespace {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false, nil
+		}
+	}
+
+	if r.namespaceSelector == nil {
+		return true, nil
+	}
+
+	namespaceObj := &corev1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: namespace}, namespaceObj); err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("could not get namespace %s: %w", namespace, err)
+	}
+
+	return r.namespaceSelector.Matches(labels.Set(namespaceObj.Labels)), nil
+}
+
+// namespacePolicyDefaults returns the `ingress.pomerium.io/*` annotations of namespace.  A namespace which does not exist
+// has no defaults.
+func (r *Reconciler) namespacePolicyDefaults(ctx context.Context, namespace string) (map[string]string, error) {
+	if namespace == "" {
+		return nil, nil
+	}
+
+	namespaceObj := &corev1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: namespace}, namespaceObj); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("could not get namespace %s: %w", namespace, err)
+	}
+
+	defaults := make(map[string]string)
+	for k, v := range namespaceObj.Annotations {
+		if strings.HasPrefix(k, policyAnnotationPrefix) {
+			defaults[k] = v
+		}
+	}
+	return defaults, nil
+}
+
+// RequestsForNamespace maps a Namespace onto requests for every resource of the Reconciler's type within it, so routes
+// follow changes to namespace policy defaults and namespaces starting or stopping to match the namespace selector
+func (r *Reconciler) RequestsForNamespace(obj client.Object) []reconcile.Request {
+	requests := make([]reconcile.Request, 0)
+
+	objs, err := r.listObjects(context.Background(), obj.GetName())
+	if err != nil {
+		logger.Error(err, "could not list resources for namespace", "namespace", obj.GetName())
+		return requests
+	}
+
+	for _, o := range objs {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(o)})
+	}
+	return requests
+}
+
+// listObjects returns the resources of the Reconciler's type in namespace
+func (r *Reconciler) listObjects(ctx context.Context, namespace string) ([]client.Object, error) {
+	if _, ok := r.kind.(*corev1.Service); !ok {
+		return r.listIngresses(ctx, client.InNamespace(namespace))
+	}
+
+	list := &corev1.ServiceList{}
+	if err := r.List(ctx, list, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+
+	objs := make([]client.Object, 0, len(list.Items))
+	for i := range list.Items {
+		objs = append(objs, &list.Items[i])
+	}
+	return objs, nil
+}
@@ -0,0 +1,197 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pomerium/pomerium-operator/internal/configmanager"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func newTestNamespace(name string, labels map[string]string) *corev1.Namespace {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+}
+
+func Test_namespaceMatch(t *testing.T) {
+	enabled := labels.SelectorFromSet(labels.Set{"pomerium.io/enabled": "true"})
+
+	tests := []struct {
+		name       string
+		namespaces []string
+		selector   labels.Selector
+		fakeObjs   []runtime.Object
+		namespace  string
+		want       bool
+	}{
+		{name: "all namespaces", namespace: "test", want: true},
+		{name: "listed namespace", namespaces: []string{"other", "test"}, namespace: "test", want: true},
+		{name: "unlisted namespace", namespaces: []string{"other"}, namespace: "test", want: false},
+		{
+			name:      "selected namespace",
+			selector:  enabled,
+			fakeObjs:  []runtime.Object{newTestNamespace("test", map[string]string{"pomerium.io/enabled": "true"})},
+			namespace: "test",
+			want:      true,
+		},
+		{
+			name:      "unselected namespace",
+			selector:  enabled,
+			fakeObjs:  []runtime.Object{newTestNamespace("test", nil)},
+			namespace: "test",
+			want:      false,
+		},
+		{name: "missing namespace", selector: enabled, namespace: "test", want: false},
+		{
+			name:       "selected but unlisted namespace",
+			namespaces: []string{"other"},
+			selector:   enabled,
+			fakeObjs:   []runtime.Object{newTestNamespace("test", map[string]string{"pomerium.io/enabled": "true"})},
+			namespace:  "test",
+			want:       false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Reconciler{}
+			assert.NoError(t, r.InjectClient(fake.NewFakeClient(tt.fakeObjs...)))
+			r.SetNamespaces(tt.namespaces)
+			r.SetNamespaceSelector(tt.selector)
+
+			got, err := r.namespaceMatch(context.Background(), tt.namespace)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_Reconciler_RequestsForNamespace(t *testing.T) {
+	c := fake.NewFakeClient(
+		newTestBackendIngress("a", "test", "backend"),
+		newTestBackendIngress("b", "other", "backend"),
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "backend", Namespace: "test"}},
+	)
+	namespace := newTestNamespace("test", nil)
+
+	ingressReconciler := NewReconciler(&networkingv1.Ingress{}, "pomerium", configmanager.NewConfigManager("test", "test", c, time.Nanosecond*1))
+	assert.NoError(t, ingressReconciler.InjectClient(c))
+	assert.Equal(t, []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Name: "a", Namespace: "test"}},
+	}, ingressReconciler.RequestsForNamespace(namespace))
+
+	serviceReconciler := NewReconciler(&corev1.Service{}, "pomerium", configmanager.NewConfigManager("test", "test", c, time.Nanosecond*1))
+	assert.NoError(t, serviceReconciler.InjectClient(c))
+	assert.Equal(t, []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Name: "backend", Namespace: "test"}},
+	}, serviceReconciler.RequestsForNamespace(namespace))
+}
+
+func Test_Reconcile_namespaceLeavesSelector(t *testing.T) {
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "service",
+			Namespace: "test",
+			Annotations: map[string]string{
+				"ingress.pomerium.io/allowed_groups": `["foo","bar"]`,
+				"ingress.pomerium.io/from":           `https://test.lan.beyondcorp.org`,
+			},
+		},
+		Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "https", Port: 443}}},
+	}
+	namespace := newTestNamespace("test", map[string]string{"pomerium.io/enabled": "true"})
+
+	c := fake.NewFakeClient(service, namespace)
+	cm := configmanager.NewConfigManager("test", "test", c, time.Nanosecond*1)
+	r := NewReconciler(&corev1.Service{}, "pomerium", cm)
+	assert.NoError(t, r.InjectClient(c))
+	r.SetNamespaceSelector(labels.SelectorFromSet(labels.Set{"pomerium.io/enabled": "true"}))
+
+	request := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(service)}
+	currentPolicies := func() int {
+		options, err := cm.GetCurrentConfig()
+		assert.NoError(t, err)
+		return len(options.Policies)
+	}
+
+	_, err := r.Reconcile(context.Background(), request)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, currentPolicies())
+
+	current := &corev1.Namespace{}
+	assert.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(namespace), current))
+	current.Labels = nil
+	assert.NoError(t, c.Update(context.Background(), current))
+
+	_, err = r.Reconcile(context.Background(), request)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, currentPolicies())
+}
+
+func Test_Reconcile_namespaceDefaultsForDefaultIngressClass(t *testing.T) {
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "ingress", Namespace: "test"},
+		Spec: networkingv1.IngressSpec{
+			Rules: []networkingv1.IngressRule{{
+				Host: "test.lan.beyondcorp.org",
+				IngressRuleValue: networkingv1.IngressRuleValue{
+					HTTP: &networkingv1.HTTPIngressRuleValue{
+						Paths: []networkingv1.HTTPIngressPath{{
+							Backend: networkingv1.IngressBackend{
+								Service: &networkingv1.IngressServiceBackend{
+									Name: "service",
+									Port: networkingv1.ServiceBackendPort{Number: 443},
+								},
+							},
+						}},
+					},
+				},
+			}},
+		},
+	}
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:        "test",
+		Annotations: map[string]string{"ingress.pomerium.io/allowed_groups": `["namespace"]`},
+	}}
+	ingressClass := &networkingv1.IngressClass{
+		ObjectMeta: metav1.ObjectMeta{Name: "pomerium", Annotations: map[string]string{defaultIngressClassAnnotation: "true"}},
+		Spec:       networkingv1.IngressClassSpec{Controller: testIngressController},
+	}
+
+	c := fake.NewFakeClient(ingress, namespace, ingressClass)
+	cm := configmanager.NewConfigManager("test", "test", c, time.Nanosecond*1)
+	r := NewReconciler(&networkingv1.Ingress{}, "pomerium", cm)
+	assert.NoError(t, r.InjectClient(c))
+	r.SetIngressController(testIngressController)
+
+	_, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: client.ObjectKeyFromObject(ingress)})
+	assert.NoError(t, err)
+
+	options, err := cm.GetCurrentConfig()
+	assert.NoError(t, err)
+	if assert.Len(t, options.Policies, 1) {
+		assert.Equal(t, []string{"namespace"}, options.Policies[0].AllowedGroups)
+	}
+
+	// Ingresses of another class are not claimed and get no defaults
+	current := &networkingv1.Ingress{}
+	assert.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(ingress), current))
+	className := "nginx"
+	current.Spec.IngressClassName = &className
+	assert.NoError(t, c.Update(context.Background(), current))
+
+	_, err = r.Reconcile(context.Background(), reconcile.Request{NamespacedName: client.ObjectKeyFromObject(ingress)})
+	assert.NoError(t, err)
+	options, err = cm.GetCurrentConfig()
+	assert.NoError(t, err)
+	assert.Empty(t, options.Policies)
+}
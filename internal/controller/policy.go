@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"net/url"
+	"regexp"
+	"sort"
 	"strings"
 
 	"k8s.io/apimachinery/pkg/util/intstr"
@@ -18,23 +20,34 @@ import (
 
 	pomeriumconfig "github.com/pomerium/pomerium/config"
 	networkingv1beta1 "k8s.io/api/extensions/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// policyAnnotationPrefix is the prefix of annotations mapped onto policy options
+const policyAnnotationPrefix = "ingress.pomerium.io/"
+
 // policyFromObj returns a pomerium []Policy, mapping the pomerium policy
 // parameters onto each Policy implied by the collection of Backends or the
 // Service described by obj
 //
-// All annotations on obj are attached to each Policy element.
+// All annotations on obj are attached to each Policy element.  `ingress.pomerium.io/*` annotations on the Namespace of
+// obj are used as defaults, see namespacePolicyDefaults, so obj must already be claimed by the Reconciler.  A policy template named by the `ingress.pomerium.io/template`
+// annotation overrides the namespace defaults and is overridden by the annotations of obj.
+// `ingress.pomerium.io/<option>_secret` annotations read the value of option from a Secret in the namespace of obj.
+// If the merged options are not permitted by the policy rules, an event is recorded and no policy is returned.  Policies
+// routing from hosts the namespace of obj may not claim under the host rules are dropped with an event.
+// Service ports selected by a `tcp` backend-protocol or the tcp-ports annotation become TCP routes from the host of
+// the `from` annotation.
 //
-// If there are no pomerium related annotations, a zero length []Policy will be returned
+// If there are no pomerium related annotations on obj or its Namespace, a zero length []Policy will be returned
 func (r *Reconciler) policyFromObj(obj runtime.Object) ([]pomeriumconfig.Policy, error) {
 
 	metaObj, ok := obj.(metav1.Object)
 	if !ok {
-		return nil, fmt.Errorf("passed an object which is not of type meta/v1/Object")
+		return nil, validationError{fmt.Errorf("passed an object which is not of type meta/v1/Object")}
 	}
 
 	annotations := metaObj.GetAnnotations()
@@ -44,33 +57,78 @@ func (r *Reconciler) policyFromObj(obj runtime.Object) ([]pomeriumconfig.Policy,
 		scheme = "http"
 	}
 	scheme = strings.ToLower(scheme)
+	if _, isService := obj.(*corev1.Service); scheme == protocolTCP && !isService {
+		r.event(obj, corev1.EventTypeWarning, reasonInvalidAnnotation, "backend-protocol %s is only supported on Services", protocolTCP)
+		return []pomeriumconfig.Policy{}, nil
+	}
 
-	policyOptions := make(map[string]string)
+	useRegex := strings.ToLower(annotations["pomerium.ingress.kubernetes.io/path-regex"]) == "true"
 
+	ownAnnotations := make(map[string]string)
 	for k, v := range annotations {
 		// Filter to only the pomerium ingress prefix
-		if strings.HasPrefix(k, "ingress.pomerium.io/") {
+		if strings.HasPrefix(k, policyAnnotationPrefix) {
+			ownAnnotations[k] = v
+		}
+	}
+
+	// Namespace annotations are defaults for every resource claimed by the Reconciler, and are overridden by their own
+	// annotations
+	defaultAnnotations, err := r.namespacePolicyDefaults(context.Background(), metaObj.GetNamespace())
+	if err != nil {
+		return nil, err
+	}
 
-			policyKey := strings.SplitN(k, "/", 2)[1]
-			valueBytes := []byte(v)
+	// A template sits between the namespace defaults and the resource's own annotations
+	templateName, useTemplate := ownAnnotations[templateAnnotation]
+	if !useTemplate {
+		templateName, useTemplate = defaultAnnotations[templateAnnotation]
+	}
+	delete(ownAnnotations, templateAnnotation)
+	delete(defaultAnnotations, templateAnnotation)
 
-			// Support yaml or json in the annotation
-			valueJSON, err := gyaml.YAMLToJSON(valueBytes)
+	policyOptions := make(map[string]string)
 
-			if err != nil {
-				policyOptions[policyKey] = v
-			} else {
-				policyOptions[policyKey] = string(valueJSON)
-			}
+	defaultOptions, ok, err := r.annotationPolicyOptions(obj, defaultAnnotations)
+	if err != nil || !ok {
+		return []pomeriumconfig.Policy{}, err
+	}
+	for k, v := range defaultOptions {
+		policyOptions[k] = v
+	}
 
+	if useTemplate {
+		templateOptions, ok, err := r.templatePolicyOptions(context.Background(), obj, templateName)
+		if err != nil || !ok {
+			return []pomeriumconfig.Policy{}, err
+		}
+		for k, v := range templateOptions {
+			policyOptions[k] = v
 		}
 	}
 
+	ownOptions, ok, err := r.annotationPolicyOptions(obj, ownAnnotations)
+	if err != nil || !ok {
+		return []pomeriumconfig.Policy{}, err
+	}
+	for k, v := range ownOptions {
+		policyOptions[k] = v
+	}
+
 	// If there are no policy annotations, skip this resource
 	if len(policyOptions) == 0 {
 		return []pomeriumconfig.Policy{}, nil
 	}
 
+	forbidden, err := r.forbiddenPolicyOptions(context.Background(), metaObj.GetNamespace(), policyOptions)
+	if err != nil {
+		return nil, err
+	}
+	if len(forbidden) > 0 {
+		r.event(obj, corev1.EventTypeWarning, reasonForbiddenPolicy, "policy rejected by policy rules: %s", strings.Join(forbidden, "; "))
+		return []pomeriumconfig.Policy{}, nil
+	}
+
 	// coerce an actual JSON structure from the escaped value in the annotation
 	policyOptionsUnescaped := make([]string, 0)
 	for k, v := range policyOptions {
@@ -78,111 +136,400 @@ func (r *Reconciler) policyFromObj(obj runtime.Object) ([]pomeriumconfig.Policy,
 	}
 	policyOptionsJSON := "{" + strings.Join(policyOptionsUnescaped, ",") + "}"
 
-	policies, err := r.policyHostnamesFromObj(obj.(runtime.Object), scheme)
+	policies, err := r.policyHostnamesFromObj(obj.(runtime.Object), scheme, useRegex)
 	if err != nil {
 		return nil, err
 	}
 
+	hostRules, err := r.loadHostRules(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	// The number of TCP ports routed decides whether the `from` annotation may set a port
+	tcpPlaceholders := make(map[string]bool)
+	for _, policy := range policies {
+		if strings.HasPrefix(policy.From, tcpFromScheme+"://") {
+			tcpPlaceholders[policy.From] = true
+		}
+	}
+
 	validatedPolicies := make([]pomeriumconfig.Policy, 0)
+	deniedHosts := 0
 	// merge settings from annotations onto each policy
 	for k := range policies {
+		// TCP routes carry their port in a placeholder `from` until the annotations supply the host
+		tcpPlaceholder := ""
+		if strings.HasPrefix(policies[k].From, tcpFromScheme+"://") {
+			tcpPlaceholder = policies[k].From
+		}
+
 		if err := yaml.Unmarshal([]byte(policyOptionsJSON), &policies[k]); err != nil {
-			return nil, fmt.Errorf("failed to insert policy options into policy: %w", err)
+			r.event(obj, corev1.EventTypeWarning, reasonInvalidAnnotation, "could not apply pomerium annotations to policy: %s", err)
+			return []pomeriumconfig.Policy{}, nil
+		}
+
+		if tcpPlaceholder != "" {
+			if err := setTCPPolicyFrom(&policies[k], tcpPlaceholder, len(tcpPlaceholders)); err != nil {
+				r.event(obj, corev1.EventTypeWarning, reasonInvalidPolicy, "ignoring invalid TCP route to %q: %s", policies[k].To, err)
+				continue
+			}
+		}
+
+		// Hosts are checked once the `from` annotation of a Service has been applied
+		permitted, err := r.hostPermitted(context.Background(), hostRules, metaObj.GetNamespace(), policies[k].From)
+		if err != nil {
+			return nil, err
+		}
+		if !permitted {
+			r.event(obj, corev1.EventTypeWarning, reasonForbiddenHost, "dropping route from %q: host is not permitted in namespace %s", policies[k].From, metaObj.GetNamespace())
+			deniedHosts++
+			continue
 		}
 
 		testPolicy := policies[k]
 		// We can only validate policies after annotations are fully merged.
 		if err := testPolicy.Validate(); err != nil {
 			logger.Info("ignoring invalid policy", "validation-error", err)
+			r.event(obj, corev1.EventTypeWarning, reasonInvalidPolicy, "ignoring invalid policy from %q to %q: %s", testPolicy.From, testPolicy.To, err)
 			continue
 		}
 
 		validatedPolicies = append(validatedPolicies, policies[k])
 
 	}
+	r.setDeniedHosts(obj, deniedHosts)
 	return validatedPolicies, nil
 }
 
+// annotationPolicyOptions returns the JSON policy option values set by the `ingress.pomerium.io/*` annotations in
+// policyAnnotations.  Secret references replace any inline value of the same option.
+//
+// If an annotation is invalid, an event is recorded against obj and false is returned.  An error is returned if a
+// Secret reference cannot be resolved.
+func (r *Reconciler) annotationPolicyOptions(obj runtime.Object, policyAnnotations map[string]string) (map[string]string, bool, error) {
+	policyOptions := make(map[string]string)
+
+	for k, v := range policyAnnotations {
+		if _, ok := secretRefOption(k); ok {
+			continue
+		}
+		policyKey := strings.SplitN(k, "/", 2)[1]
+		valueBytes := []byte(v)
+
+		// Support yaml or json in the annotation
+		valueJSON, err := gyaml.YAMLToJSON(valueBytes)
+		if err != nil {
+			r.event(obj, corev1.EventTypeWarning, reasonInvalidAnnotation, "annotation %s is not valid YAML or JSON: %s", k, err)
+			return nil, false, nil
+		}
+
+		policyOptions[policyKey] = string(valueJSON)
+	}
+
+	for k, v := range policyAnnotations {
+		option, ok := secretRefOption(k)
+		if !ok {
+			continue
+		}
+
+		ref, err := parseSecretRef(v)
+		if err != nil {
+			r.event(obj, corev1.EventTypeWarning, reasonInvalidAnnotation, "invalid %s annotation: %s", k, err)
+			return nil, false, nil
+		}
+
+		valueJSON, err := r.secretRefValue(context.Background(), obj.(metav1.Object).GetNamespace(), option, ref)
+		if err != nil {
+			r.event(obj, corev1.EventTypeWarning, reasonUnresolvableSecret, "could not resolve %s annotation: %s", k, err)
+			return nil, false, reportedError{fmt.Errorf("could not resolve %s annotation: %w", k, err)}
+		}
+		policyOptions[option] = valueJSON
+	}
+
+	return policyOptions, true, nil
+}
+
 // policyHostnamesFromObj returns an array of pomerium policies with the `to` and `from` values mapped
 // from the underlying kubernetes data.
 //
-// In practice, this returns an element for each service port or an element for every host rule + backend on an Ingress.
-func (r *Reconciler) policyHostnamesFromObj(obj runtime.Object, scheme string) (policies []pomeriumconfig.Policy, err error) {
+// In practice, this returns an element for each service port or an element for every host rule + path on an Ingress.
+// Service ports routed as TCP tunnels have a placeholder `from` carrying the port, see tcpFromPlaceholder.
+// Ingress paths are mapped onto prefix, path or regex matching according to their pathType.  useRegex treats
+// ImplementationSpecific paths as regular expressions.
+func (r *Reconciler) policyHostnamesFromObj(obj runtime.Object, scheme string, useRegex bool) (policies []pomeriumconfig.Policy, err error) {
 	policies = make([]pomeriumconfig.Policy, 0)
 	resource, err := configmanager.NewResourceIdentifierFromObj(obj.(metav1.Object))
 	if err != nil {
 		return policies, err
 	}
 
+	strategy, err := r.addressStrategyFor(obj.(metav1.Object))
+	if err != nil {
+		r.event(obj, corev1.EventTypeWarning, reasonInvalidAnnotation, "invalid %s annotation: %s", addressStrategyAnnotation, err)
+		return policies, nil
+	}
+	upstreamHost, err := upstreamHostFor(obj.(metav1.Object))
+	if err != nil {
+		r.event(obj, corev1.EventTypeWarning, reasonInvalidAnnotation, "invalid %s annotation: %s", upstreamHostAnnotation, err)
+		return policies, nil
+	}
+
 	switch kind := obj.(type) {
 	case *corev1.Service:
+		tcpPorts, err := tcpServicePorts(kind, scheme)
+		if err != nil {
+			r.event(obj, corev1.EventTypeWarning, reasonInvalidAnnotation, "invalid %s annotation: %s", tcpPortsAnnotation, err)
+			return policies, nil
+		}
+
 		for _, port := range kind.Spec.Ports {
-			to := fmt.Sprintf("%s://%s.%s.svc.cluster.local:%d", scheme, resource.NamespacedName.Name, resource.NamespacedName.Namespace, port.Port)
-			policies = append(policies, pomeriumconfig.Policy{To: to})
+			hostPort, err := r.serviceHostPort(resource.NamespacedName.Name, resource.NamespacedName.Namespace, kind, port.Port, strategy)
+			if err != nil {
+				r.event(obj, corev1.EventTypeWarning, reasonUnresolvableBackend, "could not resolve service address: %s", err)
+				return policies, reportedError{fmt.Errorf("failed to form address for service: %w", err)}
+			}
+
+			policy := pomeriumconfig.Policy{}
+			portScheme := scheme
+			if tcpPorts[port.Port] {
+				portScheme = protocolTCP
+				policy.From = tcpFromPlaceholder(port.Port)
+			}
+			policies = append(policies, policyTo(policy, portScheme, url.URL{Host: hostPort}))
 		}
 	case *networkingv1beta1.Ingress:
 		for _, rule := range kind.Spec.Rules {
+			if rule.HTTP == nil {
+				continue
+			}
 			from := fmt.Sprintf("https://%s", rule.Host)
 			for _, path := range rule.HTTP.Paths {
-				// to := fmt.Sprintf("%s://%s.%s.svc.cluster.local:%d", scheme, path.Backend.ServiceName, resource.NamespacedName.Namespace, path.Backend.ServicePort.IntValue())
-				backendURL, err := r.backendToURL(path.Backend, resource.NamespacedName.Namespace)
+				if path.Backend.Resource != nil {
+					logger.V(1).Info("skipping resource backend", "resource", resource, "host", rule.Host)
+					continue
+				}
+
+				backendURL, err := r.backendToURL(path.Backend, resource.NamespacedName.Namespace, strategy)
 				if err != nil {
-					return policies, fmt.Errorf("failed to form DNS for rule '%s' backend: %w", rule.Host, err)
+					r.event(obj, corev1.EventTypeWarning, reasonUnresolvableBackend, "could not resolve backend %s for rule '%s': %s", path.Backend.ServiceName, rule.Host, err)
+					return policies, reportedError{fmt.Errorf("failed to form address for rule '%s' backend: %w", rule.Host, err)}
 				}
 
-				backendURL.Scheme = scheme
-				policies = append(policies, pomeriumconfig.Policy{To: backendURL.String(), From: from})
+				var pathType string
+				if path.PathType != nil {
+					pathType = string(*path.PathType)
+				}
+
+				policy := pomeriumconfig.Policy{From: from}
+				setPolicyPath(&policy, path.Path, pathType, useRegex)
+				policies = append(policies, policyTo(policy, scheme, backendURL))
 			}
 		}
-		if kind.Spec.Backend != nil {
-			// to := fmt.Sprintf("%s://%s.%s.svc.cluster.local:%d", scheme, kind.Spec.Backend.ServiceName, resource.NamespacedName.Namespace, kind.Spec.Backend.ServicePort.IntValue())
-			backendURL, err := r.backendToURL(*kind.Spec.Backend, resource.NamespacedName.Namespace)
+		if kind.Spec.Backend != nil && kind.Spec.Backend.Resource == nil {
+			backendURL, err := r.backendToURL(*kind.Spec.Backend, resource.NamespacedName.Namespace, strategy)
 			if err != nil {
-				return policies, fmt.Errorf("failed to form DNS for backend: %w", err)
+				r.event(obj, corev1.EventTypeWarning, reasonUnresolvableBackend, "could not resolve default backend %s: %s", kind.Spec.Backend.ServiceName, err)
+				return policies, reportedError{fmt.Errorf("failed to form address for backend: %w", err)}
 			}
 
-			backendURL.Scheme = scheme
-			policies = append(policies, pomeriumconfig.Policy{To: backendURL.String()})
+			policies = append(policies, policyTo(pomeriumconfig.Policy{}, scheme, backendURL))
+		}
+	case *networkingv1.Ingress:
+		for _, rule := range kind.Spec.Rules {
+			if rule.HTTP == nil {
+				continue
+			}
+			from := fmt.Sprintf("https://%s", rule.Host)
+			for _, path := range rule.HTTP.Paths {
+				if path.Backend.Service == nil {
+					logger.V(1).Info("skipping resource backend", "resource", resource, "host", rule.Host)
+					continue
+				}
+
+				backendURL, err := r.serviceBackendToURL(*path.Backend.Service, resource.NamespacedName.Namespace, strategy)
+				if err != nil {
+					r.event(obj, corev1.EventTypeWarning, reasonUnresolvableBackend, "could not resolve backend %s for rule '%s': %s", path.Backend.Service.Name, rule.Host, err)
+					return policies, reportedError{fmt.Errorf("failed to form address for rule '%s' backend: %w", rule.Host, err)}
+				}
+
+				var pathType string
+				if path.PathType != nil {
+					pathType = string(*path.PathType)
+				}
+
+				policy := pomeriumconfig.Policy{From: from}
+				setPolicyPath(&policy, path.Path, pathType, useRegex)
+				policies = append(policies, policyTo(policy, scheme, backendURL))
+			}
+		}
+		if kind.Spec.DefaultBackend != nil && kind.Spec.DefaultBackend.Service != nil {
+			backendURL, err := r.serviceBackendToURL(*kind.Spec.DefaultBackend.Service, resource.NamespacedName.Namespace, strategy)
+			if err != nil {
+				r.event(obj, corev1.EventTypeWarning, reasonUnresolvableBackend, "could not resolve default backend %s: %s", kind.Spec.DefaultBackend.Service.Name, err)
+				return policies, reportedError{fmt.Errorf("failed to form address for backend: %w", err)}
+			}
+
+			policies = append(policies, policyTo(pomeriumconfig.Policy{}, scheme, backendURL))
 		}
 	default:
-		return policies, fmt.Errorf("received an incompatible object kind: %s", kind.GetObjectKind().GroupVersionKind().String())
+		return policies, validationError{fmt.Errorf("received an incompatible object kind: %s", kind.GetObjectKind().GroupVersionKind().String())}
+	}
+
+	if upstreamHost != "" {
+		for i := range policies {
+			toURL, err := url.Parse(policies[i].To)
+			if err != nil {
+				return policies, validationError{fmt.Errorf("could not parse upstream %q: %w", policies[i].To, err)}
+			}
+			upstream := withUpstreamHost(*toURL, upstreamHost)
+			policies[i].To = upstream.String()
+		}
 	}
+
+	// Pomerium uses the first matching policy, so longer paths must come first whatever their type
+	sort.SliceStable(policies, func(i, j int) bool {
+		iLen, iRank := pathSpecificity(policies[i])
+		jLen, jRank := pathSpecificity(policies[j])
+		if iLen != jLen {
+			return iLen > jLen
+		}
+		return iRank > jRank
+	})
 	return policies, nil
 }
 
-// backendToURL converts an IngressBackend for a given namespace into a url.URL
-func (r *Reconciler) backendToURL(backend networkingv1beta1.IngressBackend, namespace string) (serviceDNS url.URL, err error) {
+// setPolicyPath maps an Ingress path and pathType onto the path matching fields of policy.
+//
+// Exact paths match the full path.  Prefix paths match element-wise like Kubernetes, so `/foo` matches `/foo/bar` but
+// not `/foobar`, which Pomerium's string prefix cannot express and is matched with a regular expression instead.
+// ImplementationSpecific paths match as a Pomerium string prefix, or as a regular expression if useRegex is set.  An
+// empty path or a `/` prefix matches everything.
+func setPolicyPath(policy *pomeriumconfig.Policy, path string, pathType string, useRegex bool) {
+	if path == "" {
+		return
+	}
+
+	switch pathType {
+	case string(networkingv1.PathTypeExact):
+		policy.Path = path
+	case string(networkingv1.PathTypePrefix):
+		if path != "/" {
+			policy.Regex = prefixPathRegex(path)
+		}
+	default:
+		switch {
+		case useRegex:
+			policy.Regex = path
+		case path != "/":
+			policy.Prefix = path
+		}
+	}
+}
+
+// prefixPathRegex returns a regular expression matching path and the paths below it, element by element.  A trailing
+// `/` is ignored.
+func prefixPathRegex(path string) string {
+	return "^" + regexp.QuoteMeta(strings.TrimSuffix(path, "/")) + "(/.*)?$"
+}
+
+// prefixPathFromRegex returns the path of a regular expression built by prefixPathRegex
+func prefixPathFromRegex(re string) (string, bool) {
+	if !strings.HasPrefix(re, "^") || !strings.HasSuffix(re, "(/.*)?$") {
+		return "", false
+	}
+	quoted := strings.TrimSuffix(strings.TrimPrefix(re, "^"), "(/.*)?$")
+
+	var path strings.Builder
+	for i := 0; i < len(quoted); i++ {
+		if quoted[i] == '\\' && i+1 < len(quoted) {
+			i++
+		}
+		path.WriteByte(quoted[i])
+	}
+	if regexp.QuoteMeta(path.String()) != quoted {
+		return "", false
+	}
+	return path.String(), true
+}
+
+// pathSpecificity returns the length of the path matched by a policy and ranks its path matching.  Like Ingress
+// controllers, the longest path wins; at equal length exact paths rank above regular expressions, which rank above
+// prefixes.  Prefix paths are measured without their regular expression, and the regular expression of the path-regex
+// annotation stands in for its path.
+func pathSpecificity(policy pomeriumconfig.Policy) (length int, rank int) {
+	switch {
+	case policy.Path != "":
+		return len(policy.Path), 3
+	case policy.Regex != "":
+		if path, ok := prefixPathFromRegex(policy.Regex); ok {
+			return len(path), 2
+		}
+		return len(policy.Regex), 2
+	case policy.Prefix != "":
+		return len(policy.Prefix), 1
+	}
+	return 0, 0
+}
+
+// backendToURL converts an extensions/v1beta1 IngressBackend for a given namespace into a url.URL
+func (r *Reconciler) backendToURL(backend networkingv1beta1.IngressBackend, namespace string, strategy AddressStrategy) (url.URL, error) {
+	return r.serviceToURL(backend.ServiceName, backend.ServicePort, namespace, strategy)
+}
+
+// serviceBackendToURL converts a networking/v1 IngressServiceBackend for a given namespace into a url.URL
+func (r *Reconciler) serviceBackendToURL(backend networkingv1.IngressServiceBackend, namespace string, strategy AddressStrategy) (url.URL, error) {
+	port := intstr.FromInt(int(backend.Port.Number))
+	if backend.Port.Name != "" {
+		port = intstr.FromString(backend.Port.Name)
+	}
+	return r.serviceToURL(backend.Name, port, namespace, strategy)
+}
+
+// serviceToURL converts a Service name and numeric or named port in a given namespace into a url.URL addressed
+// according to strategy
+func (r *Reconciler) serviceToURL(serviceName string, servicePort intstr.IntOrString, namespace string, strategy AddressStrategy) (serviceURL url.URL, err error) {
+	serviceRef := types.NamespacedName{Name: serviceName, Namespace: namespace}
+
+	// The Service is only needed to resolve named ports or its cluster IP
+	var serviceObj *corev1.Service
+	if servicePort.Type == intstr.String || strategy == AddressStrategyClusterIP {
+		serviceObj = &corev1.Service{}
+		if err := r.Get(context.Background(), serviceRef, serviceObj); err != nil {
+			return serviceURL, fmt.Errorf("could not get service %s: %w", serviceRef, err)
+		}
+	}
 
 	var portNum int32
-	switch portType := backend.ServicePort.Type; portType {
+	switch portType := servicePort.Type; portType {
 	case intstr.Int:
-		portNum = int32(backend.ServicePort.IntValue())
+		portNum = int32(servicePort.IntValue())
 	case intstr.String:
-		serviceRef := types.NamespacedName{Name: backend.ServiceName, Namespace: namespace}
-		portNum, err = r.portFromService(serviceRef, backend.ServicePort.String())
+		portNum, err = portFromService(serviceObj, servicePort.String())
 
 		if err != nil {
-			return serviceDNS, fmt.Errorf("could not convert string ServicePort to integer: %w", err)
+			return serviceURL, validationError{fmt.Errorf("could not convert string ServicePort to integer: %w", err)}
 		}
 	}
 
-	serviceDNS.Host = fmt.Sprintf("%s.%s.svc.cluster.local:%d", backend.ServiceName, namespace, portNum)
+	serviceURL.Host, err = r.serviceHostPort(serviceName, namespace, serviceObj, portNum, strategy)
+	return serviceURL, err
+}
 
-	return serviceDNS, nil
+// policyTo returns policy pointed at upstream using scheme
+func policyTo(policy pomeriumconfig.Policy, scheme string, upstream url.URL) pomeriumconfig.Policy {
+	upstream.Scheme = scheme
+	policy.To = upstream.String()
+	return policy
 }
 
 // portFromService translates a string based port on a Service into a numeric port
-func (r *Reconciler) portFromService(service types.NamespacedName, port string) (int32, error) {
-	serviceObj := &corev1.Service{}
-	if err := r.Get(context.Background(), service, serviceObj); err != nil {
-		return 0, err
-	}
-
-	for _, servicePort := range serviceObj.Spec.Ports {
+func portFromService(service *corev1.Service, port string) (int32, error) {
+	for _, servicePort := range service.Spec.Ports {
 		if servicePort.Name == port {
 			return servicePort.Port, nil
 		}
 	}
 
-	return 0, fmt.Errorf("could not find port on service %s", service)
+	return 0, validationError{fmt.Errorf("could not find port %s on service %s/%s", port, service.Namespace, service.Name)}
 }
@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"regexp"
 	"testing"
 
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
@@ -17,6 +18,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	corev1 "k8s.io/api/core/v1"
 	networkingv1beta1 "k8s.io/api/extensions/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
 )
 
 func Test_policyFromObj(t *testing.T) {
@@ -26,6 +28,9 @@ func Test_policyFromObj(t *testing.T) {
 		obj        func() runtime.Object
 		fakeObjs   []runtime.Object
 		wantErr    bool
+
+		clusterDomain   string
+		addressStrategy AddressStrategy
 	}{
 		{
 			name: "ingress-http",
@@ -101,6 +106,290 @@ func Test_policyFromObj(t *testing.T) {
 				return o
 			},
 		},
+		{
+			name: "ingress-v1-http",
+			wantPolicy: []pomeriumconfig.Policy{
+				{
+					From:          "https://test.lan.beyondcorp.org",
+					To:            "http://test-service.default.svc.cluster.local:443",
+					AllowedGroups: []string{"foo", "bar"},
+				},
+				{
+					From:          "https://test.lan.beyondcorp.org",
+					To:            "http://test-service-string.default.svc.cluster.local:443",
+					AllowedGroups: []string{"foo", "bar"},
+				},
+			},
+			fakeObjs: []runtime.Object{
+				&corev1.Service{
+					TypeMeta: metav1.TypeMeta{
+						APIVersion: "v1",
+						Kind:       "Service",
+					},
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-service-string",
+						Namespace: "default",
+					},
+					Spec: corev1.ServiceSpec{
+						Ports: []corev1.ServicePort{
+							{Name: "https", Port: 443},
+						},
+					},
+				},
+			},
+			obj: func() runtime.Object {
+				o := &networkingv1.Ingress{}
+				o.ObjectMeta.Name = "test"
+				o.Kind = "Ingress"
+				o.Namespace = "default"
+				o.ObjectMeta.Annotations = map[string]string{
+					"ingress.pomerium.io/allowed_groups": `["foo","bar"]`,
+					"kubernetes.io/ingress.class":        "pomerium",
+				}
+				o.Spec.Rules = append(o.Spec.Rules,
+					networkingv1.IngressRule{
+						Host: "test.lan.beyondcorp.org",
+						IngressRuleValue: networkingv1.IngressRuleValue{
+							HTTP: &networkingv1.HTTPIngressRuleValue{
+								Paths: []networkingv1.HTTPIngressPath{
+									{
+										Backend: networkingv1.IngressBackend{
+											Service: &networkingv1.IngressServiceBackend{
+												Name: "test-service",
+												Port: networkingv1.ServiceBackendPort{Number: 443},
+											},
+										},
+									},
+									{
+										Backend: networkingv1.IngressBackend{
+											Service: &networkingv1.IngressServiceBackend{
+												Name: "test-service-string",
+												Port: networkingv1.ServiceBackendPort{Name: "https"},
+											},
+										},
+									},
+									{
+										Backend: networkingv1.IngressBackend{
+											Resource: &corev1.TypedLocalObjectReference{
+												Kind: "StorageBucket",
+												Name: "static-assets",
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+					networkingv1.IngressRule{
+						Host: "no-http.lan.beyondcorp.org",
+					},
+				)
+				return o
+			},
+		},
+		{
+			name:       "missing service for v1 named port",
+			wantPolicy: []pomeriumconfig.Policy{},
+			obj: func() runtime.Object {
+				o := &networkingv1.Ingress{}
+				o.ObjectMeta.Name = "test"
+				o.Kind = "Ingress"
+				o.Namespace = "default"
+				o.ObjectMeta.Annotations = map[string]string{
+					"ingress.pomerium.io/allowed_groups": `["foo","bar"]`,
+				}
+				o.Spec.DefaultBackend = &networkingv1.IngressBackend{
+					Service: &networkingv1.IngressServiceBackend{
+						Name: "default-service",
+						Port: networkingv1.ServiceBackendPort{Name: "https"},
+					},
+				}
+				return o
+			},
+			wantErr: true,
+		},
+		{
+			name: "ingress-v1-paths",
+			wantPolicy: []pomeriumconfig.Policy{
+				{
+					From:          "https://test.lan.beyondcorp.org",
+					To:            "http://exact-service.default.svc.cluster.local:80",
+					AllowedGroups: []string{"foo"},
+					Path:          "/healthz",
+				},
+				{
+					From:          "https://test.lan.beyondcorp.org",
+					To:            "http://api-service.default.svc.cluster.local:80",
+					AllowedGroups: []string{"foo"},
+					Regex:         "^/api/v2(/.*)?$",
+				},
+				{
+					From:          "https://test.lan.beyondcorp.org",
+					To:            "http://api-v1-service.default.svc.cluster.local:80",
+					AllowedGroups: []string{"foo"},
+					Prefix:        "/api",
+				},
+				{
+					From:          "https://test.lan.beyondcorp.org",
+					To:            "http://root-service.default.svc.cluster.local:80",
+					AllowedGroups: []string{"foo"},
+				},
+			},
+			obj: func() runtime.Object {
+				prefix := networkingv1.PathTypePrefix
+				exact := networkingv1.PathTypeExact
+				implementationSpecific := networkingv1.PathTypeImplementationSpecific
+				backend := func(name string) networkingv1.IngressBackend {
+					return networkingv1.IngressBackend{
+						Service: &networkingv1.IngressServiceBackend{
+							Name: name,
+							Port: networkingv1.ServiceBackendPort{Number: 80},
+						},
+					}
+				}
+
+				o := &networkingv1.Ingress{}
+				o.ObjectMeta.Name = "test"
+				o.Kind = "Ingress"
+				o.Namespace = "default"
+				o.ObjectMeta.Annotations = map[string]string{
+					"ingress.pomerium.io/allowed_groups": `["foo"]`,
+				}
+				o.Spec.Rules = append(o.Spec.Rules,
+					networkingv1.IngressRule{
+						Host: "test.lan.beyondcorp.org",
+						IngressRuleValue: networkingv1.IngressRuleValue{
+							HTTP: &networkingv1.HTTPIngressRuleValue{
+								Paths: []networkingv1.HTTPIngressPath{
+									{Path: "/", PathType: &prefix, Backend: backend("root-service")},
+									{Path: "/api", PathType: &implementationSpecific, Backend: backend("api-v1-service")},
+									{Path: "/api/v2", PathType: &prefix, Backend: backend("api-service")},
+									{Path: "/healthz", PathType: &exact, Backend: backend("exact-service")},
+								},
+							},
+						},
+					},
+				)
+				return o
+			},
+		},
+		{
+			name: "ingress-mixed-path-types",
+			wantPolicy: []pomeriumconfig.Policy{
+				{
+					From:          "https://test.lan.beyondcorp.org",
+					To:            "http://users-service.default.svc.cluster.local:80",
+					AllowedGroups: []string{"foo"},
+					Regex:         "^/api/v1/users(/.*)?$",
+				},
+				{
+					From:          "https://test.lan.beyondcorp.org",
+					To:            "http://api-v1-service.default.svc.cluster.local:80",
+					AllowedGroups: []string{"foo"},
+					Prefix:        "/api/v1",
+				},
+				{
+					From:          "https://test.lan.beyondcorp.org",
+					To:            "http://exact-service.default.svc.cluster.local:80",
+					AllowedGroups: []string{"foo"},
+					Path:          "/api",
+				},
+				{
+					From:          "https://test.lan.beyondcorp.org",
+					To:            "http://api-service.default.svc.cluster.local:80",
+					AllowedGroups: []string{"foo"},
+					Regex:         "^/api(/.*)?$",
+				},
+			},
+			obj: func() runtime.Object {
+				prefix := networkingv1.PathTypePrefix
+				exact := networkingv1.PathTypeExact
+				implementationSpecific := networkingv1.PathTypeImplementationSpecific
+				backend := func(name string) networkingv1.IngressBackend {
+					return networkingv1.IngressBackend{
+						Service: &networkingv1.IngressServiceBackend{
+							Name: name,
+							Port: networkingv1.ServiceBackendPort{Number: 80},
+						},
+					}
+				}
+
+				o := &networkingv1.Ingress{}
+				o.ObjectMeta.Name = "test"
+				o.Kind = "Ingress"
+				o.Namespace = "default"
+				o.ObjectMeta.Annotations = map[string]string{
+					"ingress.pomerium.io/allowed_groups": `["foo"]`,
+				}
+				o.Spec.Rules = append(o.Spec.Rules,
+					networkingv1.IngressRule{
+						Host: "test.lan.beyondcorp.org",
+						IngressRuleValue: networkingv1.IngressRuleValue{
+							HTTP: &networkingv1.HTTPIngressRuleValue{
+								Paths: []networkingv1.HTTPIngressPath{
+									{Path: "/api", PathType: &prefix, Backend: backend("api-service")},
+									{Path: "/api", PathType: &exact, Backend: backend("exact-service")},
+									{Path: "/api/v1", PathType: &implementationSpecific, Backend: backend("api-v1-service")},
+									{Path: "/api/v1/users", PathType: &prefix, Backend: backend("users-service")},
+								},
+							},
+						},
+					},
+				)
+				return o
+			},
+		},
+		{
+			name: "ingress-regex-paths",
+			wantPolicy: []pomeriumconfig.Policy{
+				{
+					From:          "https://test.lan.beyondcorp.org",
+					To:            "http://regex-service.default.svc.cluster.local:80",
+					AllowedGroups: []string{"foo"},
+					Regex:         "^/users/[0-9]+$",
+				},
+				{
+					From:          "https://test.lan.beyondcorp.org",
+					To:            "http://root-service.default.svc.cluster.local:80",
+					AllowedGroups: []string{"foo"},
+				},
+			},
+			obj: func() runtime.Object {
+				o := &networkingv1beta1.Ingress{}
+				o.ObjectMeta.Name = "test"
+				o.Kind = "Ingress"
+				o.Namespace = "default"
+				o.ObjectMeta.Annotations = map[string]string{
+					"ingress.pomerium.io/allowed_groups":        `["foo"]`,
+					"pomerium.ingress.kubernetes.io/path-regex": "true",
+				}
+				o.Spec.Rules = append(o.Spec.Rules,
+					networkingv1beta1.IngressRule{
+						Host: "test.lan.beyondcorp.org",
+						IngressRuleValue: networkingv1beta1.IngressRuleValue{
+							HTTP: &networkingv1beta1.HTTPIngressRuleValue{
+								Paths: []networkingv1beta1.HTTPIngressPath{
+									{
+										Backend: networkingv1beta1.IngressBackend{
+											ServiceName: "root-service",
+											ServicePort: intstr.FromInt(80),
+										},
+									},
+									{
+										Path: "^/users/[0-9]+$",
+										Backend: networkingv1beta1.IngressBackend{
+											ServiceName: "regex-service",
+											ServicePort: intstr.FromInt(80),
+										},
+									},
+								},
+							},
+						},
+					},
+				)
+				return o
+			},
+		},
 		{
 			name: "service-https",
 			wantPolicy: []pomeriumconfig.Policy{
@@ -125,6 +414,329 @@ func Test_policyFromObj(t *testing.T) {
 				return o
 			},
 		},
+		{
+			name: "service-tcp",
+			wantPolicy: []pomeriumconfig.Policy{
+				{To: "tcp://test-db.default.svc.cluster.local:5432", From: "tcp+https://db.lan.beyondcorp.org:5432", AllowedUsers: []string{"user@beyondcorp.org"}},
+			},
+			obj: func() runtime.Object {
+				o := &corev1.Service{}
+				o.Kind = "Service"
+				o.Namespace = "default"
+				o.ObjectMeta.Name = "test-db"
+				o.ObjectMeta.Annotations = map[string]string{
+					"ingress.pomerium.io/allowed_users":               `["user@beyondcorp.org"]`,
+					"pomerium.ingress.kubernetes.io/backend-protocol": "tcp",
+					"ingress.pomerium.io/from":                        "https://db.lan.beyondcorp.org",
+				}
+				o.Spec.Ports = []corev1.ServicePort{{Name: "postgres", Port: 5432}}
+				return o
+			},
+		},
+		{
+			name: "service-tcp-ports",
+			wantPolicy: []pomeriumconfig.Policy{
+				{To: "tcp://test-cache.default.svc.cluster.local:6379", From: "tcp+https://cache.lan.beyondcorp.org:6379", AllowedUsers: []string{"user@beyondcorp.org"}},
+				{To: "http://test-cache.default.svc.cluster.local:8080", From: "https://cache.lan.beyondcorp.org", AllowedUsers: []string{"user@beyondcorp.org"}},
+			},
+			obj: func() runtime.Object {
+				o := &corev1.Service{}
+				o.Kind = "Service"
+				o.Namespace = "default"
+				o.ObjectMeta.Name = "test-cache"
+				o.ObjectMeta.Annotations = map[string]string{
+					"ingress.pomerium.io/allowed_users":        `["user@beyondcorp.org"]`,
+					"pomerium.ingress.kubernetes.io/tcp-ports": "redis",
+					"ingress.pomerium.io/from":                 "https://cache.lan.beyondcorp.org",
+				}
+				o.Spec.Ports = []corev1.ServicePort{
+					{Name: "redis", Port: 6379},
+					{Name: "ui", Port: 8080},
+				}
+				return o
+			},
+		},
+		{
+			name:       "service-tcp-ports-shared-port",
+			wantPolicy: []pomeriumconfig.Policy{},
+			obj: func() runtime.Object {
+				o := &corev1.Service{}
+				o.Kind = "Service"
+				o.Namespace = "default"
+				o.ObjectMeta.Name = "test-db"
+				o.ObjectMeta.Annotations = map[string]string{
+					"ingress.pomerium.io/allowed_users":               `["user@beyondcorp.org"]`,
+					"pomerium.ingress.kubernetes.io/backend-protocol": "tcp",
+					"ingress.pomerium.io/from":                        "tcp+https://db.lan.beyondcorp.org:15432",
+				}
+				o.Spec.Ports = []corev1.ServicePort{
+					{Name: "postgres", Port: 5432},
+					{Name: "replication", Port: 5433},
+				}
+				return o
+			},
+		},
+		{
+			name:          "service-cluster-domain",
+			clusterDomain: "corp.example",
+			wantPolicy: []pomeriumconfig.Policy{
+				{To: "http://test-service.default.svc.corp.example:80", From: "https://test.lan.beyondcorp.org", AllowedUsers: []string{"user@beyondcorp.org"}},
+			},
+			obj: func() runtime.Object {
+				o := &corev1.Service{}
+				o.Kind = "Service"
+				o.Namespace = "default"
+				o.ObjectMeta.Name = "test-service"
+				o.ObjectMeta.Annotations = map[string]string{
+					"ingress.pomerium.io/allowed_users": `["user@beyondcorp.org"]`,
+					"ingress.pomerium.io/from":          "https://test.lan.beyondcorp.org",
+				}
+				o.Spec.Ports = []corev1.ServicePort{{Name: "http", Port: 80}}
+				return o
+			},
+		},
+		{
+			name:            "service-cluster-ip",
+			addressStrategy: AddressStrategyClusterIP,
+			wantPolicy: []pomeriumconfig.Policy{
+				{To: "http://10.96.0.20:80", From: "https://test.lan.beyondcorp.org", AllowedUsers: []string{"user@beyondcorp.org"}},
+			},
+			obj: func() runtime.Object {
+				o := &corev1.Service{}
+				o.Kind = "Service"
+				o.Namespace = "default"
+				o.ObjectMeta.Name = "test-service"
+				o.ObjectMeta.Annotations = map[string]string{
+					"ingress.pomerium.io/allowed_users": `["user@beyondcorp.org"]`,
+					"ingress.pomerium.io/from":          "https://test.lan.beyondcorp.org",
+				}
+				o.Spec.ClusterIP = "10.96.0.20"
+				o.Spec.Ports = []corev1.ServicePort{{Name: "http", Port: 80}}
+				return o
+			},
+		},
+		{
+			name:            "headless service-cluster-ip",
+			addressStrategy: AddressStrategyClusterIP,
+			obj: func() runtime.Object {
+				o := &corev1.Service{}
+				o.Kind = "Service"
+				o.Namespace = "default"
+				o.ObjectMeta.Name = "test-service"
+				o.ObjectMeta.Annotations = map[string]string{
+					"ingress.pomerium.io/allowed_users": `["user@beyondcorp.org"]`,
+					"ingress.pomerium.io/from":          "https://test.lan.beyondcorp.org",
+				}
+				o.Spec.ClusterIP = corev1.ClusterIPNone
+				o.Spec.Ports = []corev1.ServicePort{{Name: "http", Port: 80}}
+				return o
+			},
+			wantErr: true,
+		},
+		{
+			name: "ingress-v1-cluster-ip-annotation",
+			wantPolicy: []pomeriumconfig.Policy{
+				{
+					From:          "https://test.lan.beyondcorp.org",
+					To:            "http://10.96.0.30:8080",
+					AllowedGroups: []string{"foo"},
+				},
+			},
+			fakeObjs: []runtime.Object{
+				&corev1.Service{
+					ObjectMeta: metav1.ObjectMeta{Name: "test-service", Namespace: "default"},
+					Spec: corev1.ServiceSpec{
+						ClusterIP: "10.96.0.30",
+						Ports:     []corev1.ServicePort{{Name: "http", Port: 8080}},
+					},
+				},
+			},
+			obj: func() runtime.Object {
+				o := &networkingv1.Ingress{}
+				o.ObjectMeta.Name = "test"
+				o.Namespace = "default"
+				o.ObjectMeta.Annotations = map[string]string{
+					"ingress.pomerium.io/allowed_groups":              `["foo"]`,
+					"pomerium.ingress.kubernetes.io/address-strategy": "cluster-ip",
+				}
+				o.Spec.Rules = []networkingv1.IngressRule{{
+					Host: "test.lan.beyondcorp.org",
+					IngressRuleValue: networkingv1.IngressRuleValue{
+						HTTP: &networkingv1.HTTPIngressRuleValue{
+							Paths: []networkingv1.HTTPIngressPath{{
+								Backend: networkingv1.IngressBackend{
+									Service: &networkingv1.IngressServiceBackend{
+										Name: "test-service",
+										Port: networkingv1.ServiceBackendPort{Name: "http"},
+									},
+								},
+							}},
+						},
+					},
+				}}
+				return o
+			},
+		},
+		{
+			name: "upstream host annotation",
+			wantPolicy: []pomeriumconfig.Policy{
+				{
+					To:           "http://backend.example.internal:80",
+					From:         "https://test.lan.beyondcorp.org",
+					AllowedUsers: []string{"user@beyondcorp.org"},
+				},
+			},
+			obj: func() runtime.Object {
+				o := &corev1.Service{}
+				o.Kind = "Service"
+				o.Namespace = "default"
+				o.ObjectMeta.Name = "test-service"
+				o.ObjectMeta.Annotations = map[string]string{
+					"ingress.pomerium.io/allowed_users":            `["user@beyondcorp.org"]`,
+					"ingress.pomerium.io/from":                     "https://test.lan.beyondcorp.org",
+					"pomerium.ingress.kubernetes.io/upstream-host": "backend.example.internal",
+				}
+				o.Spec.Ports = []corev1.ServicePort{{Name: "http", Port: 80}}
+				return o
+			},
+		},
+		{
+			name:       "invalid upstream host annotation",
+			wantPolicy: []pomeriumconfig.Policy{},
+			obj: func() runtime.Object {
+				o := &corev1.Service{}
+				o.Kind = "Service"
+				o.Namespace = "default"
+				o.ObjectMeta.Name = "test-service"
+				o.ObjectMeta.Annotations = map[string]string{
+					"ingress.pomerium.io/allowed_users":            `["user@beyondcorp.org"]`,
+					"ingress.pomerium.io/from":                     "https://test.lan.beyondcorp.org",
+					"pomerium.ingress.kubernetes.io/upstream-host": "http://backend",
+				}
+				o.Spec.Ports = []corev1.ServicePort{{Name: "http", Port: 80}}
+				return o
+			},
+		},
+		{
+			name:       "invalid address strategy annotation",
+			wantPolicy: []pomeriumconfig.Policy{},
+			obj: func() runtime.Object {
+				o := &corev1.Service{}
+				o.Kind = "Service"
+				o.Namespace = "default"
+				o.ObjectMeta.Name = "test-service"
+				o.ObjectMeta.Annotations = map[string]string{
+					"ingress.pomerium.io/allowed_users":               `["user@beyondcorp.org"]`,
+					"ingress.pomerium.io/from":                        "https://test.lan.beyondcorp.org",
+					"pomerium.ingress.kubernetes.io/address-strategy": "carrier-pigeon",
+				}
+				o.Spec.Ports = []corev1.ServicePort{{Name: "http", Port: 80}}
+				return o
+			},
+		},
+		{
+			name: "namespace defaults",
+			wantPolicy: []pomeriumconfig.Policy{
+				{
+					To:            "http://test-service.default.svc.cluster.local:80",
+					From:          "https://test.lan.beyondcorp.org",
+					AllowedGroups: []string{"foo"},
+					AllowedUsers:  []string{"user@beyondcorp.org"},
+				},
+			},
+			fakeObjs: []runtime.Object{
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+					Name: "default",
+					Annotations: map[string]string{
+						"ingress.pomerium.io/allowed_groups": `["namespace"]`,
+						"ingress.pomerium.io/allowed_users":  `["user@beyondcorp.org"]`,
+						"unrelated.io/annotation":            "ignored",
+					},
+				}},
+			},
+			obj: func() runtime.Object {
+				o := &corev1.Service{}
+				o.Namespace = "default"
+				o.ObjectMeta.Name = "test-service"
+				o.ObjectMeta.Annotations = map[string]string{
+					"ingress.pomerium.io/allowed_groups": `["foo"]`,
+					"ingress.pomerium.io/from":           "https://test.lan.beyondcorp.org",
+				}
+				o.Spec.Ports = []corev1.ServicePort{{Name: "http", Port: 80}}
+				return o
+			},
+		},
+		{
+			name: "namespace defaults for claimed ingress",
+			wantPolicy: []pomeriumconfig.Policy{
+				{
+					From:          "https://test.lan.beyondcorp.org",
+					To:            "http://test-service.default.svc.cluster.local:80",
+					AllowedGroups: []string{"namespace"},
+				},
+			},
+			fakeObjs: []runtime.Object{
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+					Name:        "default",
+					Annotations: map[string]string{"ingress.pomerium.io/allowed_groups": `["namespace"]`},
+				}},
+			},
+			obj: func() runtime.Object {
+				className := "pomerium"
+				o := &networkingv1.Ingress{}
+				o.ObjectMeta.Name = "test"
+				o.Namespace = "default"
+				o.Spec.IngressClassName = &className
+				o.Spec.Rules = []networkingv1.IngressRule{{
+					Host: "test.lan.beyondcorp.org",
+					IngressRuleValue: networkingv1.IngressRuleValue{
+						HTTP: &networkingv1.HTTPIngressRuleValue{
+							Paths: []networkingv1.HTTPIngressPath{{
+								Backend: networkingv1.IngressBackend{
+									Service: &networkingv1.IngressServiceBackend{
+										Name: "test-service",
+										Port: networkingv1.ServiceBackendPort{Number: 80},
+									},
+								},
+							}},
+						},
+					},
+				}}
+				return o
+			},
+		},
+		{
+			name: "namespace defaults for ingress without a class",
+			wantPolicy: []pomeriumconfig.Policy{
+				{
+					From:          "https://test.lan.beyondcorp.org",
+					To:            "http://test-service.default.svc.cluster.local:80",
+					AllowedGroups: []string{"namespace"},
+				},
+			},
+			fakeObjs: []runtime.Object{
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+					Name:        "default",
+					Annotations: map[string]string{"ingress.pomerium.io/allowed_groups": `["namespace"]`},
+				}},
+			},
+			obj: func() runtime.Object {
+				o := &networkingv1beta1.Ingress{}
+				o.ObjectMeta.Name = "test"
+				o.Namespace = "default"
+				o.Spec.Rules = []networkingv1beta1.IngressRule{{
+					Host: "test.lan.beyondcorp.org",
+					IngressRuleValue: networkingv1beta1.IngressRuleValue{
+						HTTP: &networkingv1beta1.HTTPIngressRuleValue{
+							Paths: []networkingv1beta1.HTTPIngressPath{{
+								Backend: networkingv1beta1.IngressBackend{ServiceName: "test-service", ServicePort: intstr.FromInt(80)},
+							}},
+						},
+					},
+				}}
+				return o
+			},
+		},
 		{
 			name:       "empty",
 			wantPolicy: []pomeriumconfig.Policy{},
@@ -183,7 +795,7 @@ func Test_policyFromObj(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			obj := test.obj()
 			client := fake.NewFakeClient(test.fakeObjs...)
-			rec := &Reconciler{}
+			rec := &Reconciler{clusterDomain: test.clusterDomain, addressStrategy: test.addressStrategy}
 			err := rec.InjectClient(client)
 			assert.NoError(t, err, "failed to inject client")
 			policy, err := rec.policyFromObj(obj)
@@ -196,3 +808,65 @@ func Test_policyFromObj(t *testing.T) {
 		})
 	}
 }
+
+func Test_setPolicyPath(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		pathType   networkingv1.PathType
+		useRegex   bool
+		wantPolicy pomeriumconfig.Policy
+	}{
+		{"exact", "/foo", networkingv1.PathTypeExact, false, pomeriumconfig.Policy{Path: "/foo"}},
+		{"root prefix", "/", networkingv1.PathTypePrefix, false, pomeriumconfig.Policy{}},
+		{"prefix", "/foo", networkingv1.PathTypePrefix, false, pomeriumconfig.Policy{Regex: "^/foo(/.*)?$"}},
+		{"prefix with trailing slash", "/foo/", networkingv1.PathTypePrefix, false, pomeriumconfig.Policy{Regex: "^/foo(/.*)?$"}},
+		{"prefix with metacharacters", "/v1.0", networkingv1.PathTypePrefix, false, pomeriumconfig.Policy{Regex: `^/v1\.0(/.*)?$`}},
+		{"implementation specific", "/foo", networkingv1.PathTypeImplementationSpecific, false, pomeriumconfig.Policy{Prefix: "/foo"}},
+		{"implementation specific regex", "/fo+", networkingv1.PathTypeImplementationSpecific, true, pomeriumconfig.Policy{Regex: "/fo+"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := pomeriumconfig.Policy{}
+			setPolicyPath(&policy, tt.path, string(tt.pathType), tt.useRegex)
+			assert.Equal(t, tt.wantPolicy, policy)
+		})
+	}
+}
+
+func Test_prefixPathRegex(t *testing.T) {
+	re := regexp.MustCompile(prefixPathRegex("/foo"))
+	for path, want := range map[string]bool{
+		"/foo":     true,
+		"/foo/":    true,
+		"/foo/bar": true,
+		"/foobar":  false,
+		"/bar/foo": false,
+	} {
+		assert.Equal(t, want, re.MatchString(path), path)
+	}
+}
+
+func Test_pathSpecificity(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     pomeriumconfig.Policy
+		wantLength int
+		wantRank   int
+	}{
+		{"any path", pomeriumconfig.Policy{}, 0, 0},
+		{"exact", pomeriumconfig.Policy{Path: "/api"}, 4, 3},
+		{"prefix", pomeriumconfig.Policy{Regex: prefixPathRegex("/api/v1.0")}, 9, 2},
+		{"regex", pomeriumconfig.Policy{Regex: "^/users/[0-9]+$"}, 15, 2},
+		{"implementation specific", pomeriumconfig.Policy{Prefix: "/api/v1"}, 7, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			length, rank := pathSpecificity(tt.policy)
+			assert.Equal(t, tt.wantLength, length)
+			assert.Equal(t, tt.wantRank, rank)
+		})
+	}
+}
@@ -9,14 +9,19 @@ import (
 
 	"github.com/pomerium/pomerium-operator/internal/configmanager"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 
 	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/pomerium/pomerium-operator/internal/log"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
@@ -29,6 +34,18 @@ type Reconciler struct {
 	controllerAnnotation  string
 	controllerClass       string
 	controllerClassRegExp *regexp.Regexp
+	ingressController     string
+	publishService        types.NamespacedName
+	publishAddresses      []string
+	clusterDomain         string
+	addressStrategy       AddressStrategy
+	namespaces            []string
+	namespaceSelector     labels.Selector
+	policyTemplates       types.NamespacedName
+	policyRules           types.NamespacedName
+	hostRules             types.NamespacedName
+	validating            bool
+	recorder              record.EventRecorder
 	kind                  runtime.Object
 	scheme                *runtime.Scheme
 	configManager         *configmanager.ConfigManager
@@ -67,47 +84,117 @@ func (r *Reconciler) InjectClient(c client.Client) error {
 }
 
 // Reconcile implements the Reconciler interface and conducts a reconcile loop on a given request.  This is typically called by a controller-manager like that found inside an Operator.
+//
+// Routes are only removed when the resource no longer exists.  Any other failure is returned so the request is retried with
+// exponential backoff, leaving the previous route in place.
 func (r *Reconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
 	logger.V(1).Info("notified of change to resource", "resource", req.NamespacedName)
 
 	obj := r.newKind()
 	objName := req.NamespacedName
-	objKind := obj.GetObjectKind()
+	gvk, err := apiutil.GVKForObject(obj, r.scheme)
+	if err != nil {
+		return reconcile.Result{}, fmt.Errorf("could not determine kind of %s: %w", objName, err)
+	}
 	resource := configmanager.ResourceIdentifier{
-		GVK: objKind.GroupVersionKind(), NamespacedName: objName,
+		GVK: gvk, NamespacedName: objName,
 	}
 
 	if err := r.Get(ctx, req.NamespacedName, obj); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return reconcile.Result{}, fmt.Errorf("could not get resource %s: %w", objName, err)
+		}
+
 		logger.V(1).Info("resource deleted", "resource", resource)
 		r.RemoveRoute(resource)
-	} else {
-		logger.V(1).Info("resource added or modified", "resource", resource)
-		r.UpsertRoute(resource, obj)
+		clearDeniedHosts(resource)
+		return reconcile.Result{}, nil
+	}
+
+	logger.V(1).Info("resource added or modified", "resource", resource)
+	if err := r.UpsertRoute(resource, obj); err != nil {
+		return reconcile.Result{}, err
 	}
 
 	return reconcile.Result{}, nil
 }
 
-// UpsertRoute adds or updates a route entry into the ConfigManager associated with this Reconciler.  It will only do so if the controllerClass matches or is absent
-func (r *Reconciler) UpsertRoute(resource configmanager.ResourceIdentifier, obj runtime.Object) {
-	if !r.ControllerClassMatch(obj.(metav1.Object)) {
+// UpsertRoute adds or updates a route entry into the ConfigManager associated with this Reconciler.  It will only do so if the resource is in
+// a watched namespace and the controllerClass or IngressClass matches.  A resource which no longer matches or no longer produces any policy has its route entry removed.
+// Invalid annotations and options forbidden by the policy rules produce no policy, so they also remove the route entry
+// and are recorded as Warning events.
+//
+// If the policy cannot be generated because an object it references cannot be read or resolved, the existing route
+// entry is left unchanged.  Only transient errors are returned to be retried; other failures are recorded as Warning
+// events.
+func (r *Reconciler) UpsertRoute(resource configmanager.ResourceIdentifier, obj runtime.Object) error {
+	ctx := context.Background()
+	clientObj := obj.(client.Object)
+
+	match, err := r.namespaceMatch(ctx, clientObj.GetNamespace())
+	if err != nil {
+		return fmt.Errorf("could not determine if namespace of %s is watched: %w", resource.NamespacedName, err)
+	}
+
+	if !match {
+		logger.V(1).Info("resource is not in a watched namespace", "resource", resource)
+		r.RemoveRoute(resource)
+		return r.updateStatus(ctx, resource, clientObj, false, false)
+	}
+
+	match, err = r.classMatch(ctx, clientObj)
+	if err != nil {
+		return fmt.Errorf("could not determine class of %s: %w", resource.NamespacedName, err)
+	}
+
+	if !match {
 		logger.V(1).Info("resource does not match controller annotation", "resource", resource)
-		return
+		r.RemoveRoute(resource)
+		return r.updateStatus(ctx, resource, clientObj, false, false)
 	}
 
 	policy, err := r.policyFromObj(obj)
 	if err != nil {
-		logger.Error(err, "could not generate policy from object", "id", resource)
-		return
+		if isTransient(err) {
+			return fmt.Errorf("could not generate policy from %s: %w", resource.NamespacedName, err)
+		}
+		// Retrying cannot help, the resource is reconciled again when it or anything it references changes
+		logger.Info("could not generate policy", "resource", resource, "error", err.Error())
+		if !isReported(err) {
+			r.event(obj, corev1.EventTypeWarning, reasonInvalidPolicy, "could not generate policy: %s", err)
+		}
+		return nil
 	}
 
 	if len(policy) == 0 {
 		logger.V(1).Info("no policy generated", "resource", resource)
-		return
+		r.RemoveRoute(resource)
+		return r.updateStatus(ctx, resource, clientObj, true, false)
+	}
+
+	meta, err := routeMetaFromObj(clientObj)
+	if err != nil {
+		r.event(obj, corev1.EventTypeWarning, reasonInvalidAnnotation, "invalid %s annotation: %s", priorityAnnotation, err)
+		r.RemoveRoute(resource)
+		return r.updateStatus(ctx, resource, clientObj, true, false)
 	}
 
 	logger.V(1).Info("got resource with policy", "policy", policy, "resource", resource)
-	r.configManager.Set(resource, policy)
+	changed := r.configManager.SetWithMeta(resource, policy, meta)
+	r.configManager.SetCertificates(resource, r.certificatesFromObj(ctx, clientObj))
+	if changed {
+		r.event(obj, corev1.EventTypeNormal, reasonRouteAccepted, "accepted %d pomerium route(s)", len(policy))
+	}
+	return r.updateStatus(ctx, resource, clientObj, true, true)
+}
+
+// updateStatus publishes or clears the address of an Ingress.  claimed is set if obj is in a watched namespace and
+// matches the Reconciler's class.
+func (r *Reconciler) updateStatus(ctx context.Context, resource configmanager.ResourceIdentifier, obj client.Object, claimed bool, accepted bool) error {
+	if err := r.updateIngressStatus(ctx, obj, claimed, accepted); err != nil {
+		return fmt.Errorf("could not update status of %s: %w", resource.NamespacedName, err)
+	}
+	return nil
 }
 
 // RemoveRoute removes a route entry from the ConfigManager associated with this Reconciler, if it currently exists.
@@ -3,6 +3,7 @@ package controller
 import (
 	"context"
 	"fmt"
+	"reflect"
 	"regexp"
 	"testing"
 	"time"
@@ -14,12 +15,16 @@ import (
 	corev1 "k8s.io/api/core/v1"
 
 	networkingv1beta1 "k8s.io/api/extensions/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
 
 	"github.com/pomerium/pomerium-operator/internal/configmanager"
+	pomeriumconfig "github.com/pomerium/pomerium/config"
 	"github.com/stretchr/testify/assert"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -41,6 +46,14 @@ func Test_NewReconciler(t *testing.T) {
 			expectedClassRegExp:          nil,
 			expectedControllerAnnotation: "kubernetes.io/ingress.class",
 		},
+		{
+			name:                         "ingress-v1",
+			obj:                          &networkingv1.Ingress{},
+			class:                        "ingress",
+			expectedPanic:                false,
+			expectedClassRegExp:          nil,
+			expectedControllerAnnotation: "kubernetes.io/ingress.class",
+		},
 		{
 			name:                         "service",
 			obj:                          &corev1.Service{},
@@ -205,6 +218,32 @@ func Test_Reconcile_2(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "add-ingress-v1",
+			obj: &networkingv1.Ingress{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "networking.k8s.io/v1",
+					Kind:       "Ingress",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "ingress-v1",
+					Namespace: "test",
+					Annotations: map[string]string{
+						"ingress.pomerium.io/allowed_groups": `["foo","bar"]`,
+						"ingress.pomerium.io/from":           `https://test.lan.beyondcorp.org`,
+						"kubernetes.io/ingress.class":        "pomerium",
+					},
+				},
+				Spec: networkingv1.IngressSpec{
+					DefaultBackend: &networkingv1.IngressBackend{
+						Service: &networkingv1.IngressServiceBackend{
+							Name: "default-service",
+							Port: networkingv1.ServiceBackendPort{Number: 443},
+						},
+					},
+				},
+			},
+		},
 		{
 			name: "add-service",
 			obj: &corev1.Service{
@@ -398,3 +437,264 @@ func Test_Reconciler_ControllerClassMatch(t *testing.T) {
 		})
 	}
 }
+
+// errorClient fails Get requests for objects of the same type as failType
+type errorClient struct {
+	client.Client
+	failType client.Object
+	err      error
+}
+
+func (c *errorClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	if c.err != nil && reflect.TypeOf(obj) == reflect.TypeOf(c.failType) {
+		return c.err
+	}
+	return c.Client.Get(ctx, key, obj)
+}
+
+func Test_Reconcile_transientErrors(t *testing.T) {
+	ingress := &networkingv1.Ingress{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "networking.k8s.io/v1",
+			Kind:       "Ingress",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "ingress",
+			Namespace: "test",
+			Annotations: map[string]string{
+				"ingress.pomerium.io/allowed_groups": `["foo","bar"]`,
+			},
+		},
+		Spec: networkingv1.IngressSpec{
+			Rules: []networkingv1.IngressRule{{
+				Host: "test.lan.beyondcorp.org",
+				IngressRuleValue: networkingv1.IngressRuleValue{
+					HTTP: &networkingv1.HTTPIngressRuleValue{
+						Paths: []networkingv1.HTTPIngressPath{{
+							Backend: networkingv1.IngressBackend{
+								Service: &networkingv1.IngressServiceBackend{
+									Name: "service",
+									Port: networkingv1.ServiceBackendPort{Name: "https"},
+								},
+							},
+						}},
+					},
+				},
+			}},
+		},
+	}
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "service", Namespace: "test"},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{{Name: "https", Port: 443}},
+		},
+	}
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Name: "ingress", Namespace: "test"}}
+
+	fakeClient := fake.NewFakeClient(ingress, service)
+	c := &errorClient{Client: fakeClient, failType: &networkingv1.Ingress{}}
+	cm := configmanager.NewConfigManager("test", "test", c, time.Nanosecond*1)
+	r := NewReconciler(&networkingv1.Ingress{}, "pomerium", cm)
+	assert.NoError(t, r.InjectClient(c))
+
+	currentPolicies := func() []pomeriumconfig.Policy {
+		options, err := cm.GetCurrentConfig()
+		assert.NoError(t, err)
+		return options.Policies
+	}
+
+	_, err := r.Reconcile(context.Background(), request)
+	assert.NoError(t, err)
+	assert.Len(t, currentPolicies(), 1)
+
+	// Transient failure reading the resource keeps the route
+	c.err = apierrors.NewServerTimeout(schema.GroupResource{Group: "networking.k8s.io", Resource: "ingresses"}, "get", 1)
+	_, err = r.Reconcile(context.Background(), request)
+	assert.Error(t, err)
+	assert.Len(t, currentPolicies(), 1)
+	c.err = nil
+
+	// Failed named port lookup keeps the route, and is not retried until the Service changes
+	assert.NoError(t, fakeClient.Delete(context.Background(), service))
+	_, err = r.Reconcile(context.Background(), request)
+	assert.NoError(t, err)
+	assert.Len(t, currentPolicies(), 1)
+
+	// Transient failure resolving the backend is retried
+	c.err = apierrors.NewServerTimeout(schema.GroupResource{Resource: "services"}, "get", 1)
+	c.failType = &corev1.Service{}
+	_, err = r.Reconcile(context.Background(), request)
+	assert.Error(t, err)
+	assert.Len(t, currentPolicies(), 1)
+	c.err = nil
+
+	// Deletion removes the route
+	assert.NoError(t, fakeClient.Delete(context.Background(), ingress))
+	_, err = r.Reconcile(context.Background(), request)
+	assert.NoError(t, err)
+	assert.Empty(t, currentPolicies())
+}
+
+func Test_Reconcile_transitions(t *testing.T) {
+	tests := []struct {
+		name   string
+		obj    client.Object
+		modify func(obj client.Object)
+	}{
+		{
+			name: "ingress changes class",
+			obj: &networkingv1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "ingress",
+					Namespace: "test",
+					Annotations: map[string]string{
+						"ingress.pomerium.io/allowed_groups": `["foo","bar"]`,
+						"kubernetes.io/ingress.class":        "pomerium",
+					},
+				},
+				Spec: networkingv1.IngressSpec{
+					Rules: []networkingv1.IngressRule{{
+						Host: "test.lan.beyondcorp.org",
+						IngressRuleValue: networkingv1.IngressRuleValue{
+							HTTP: &networkingv1.HTTPIngressRuleValue{
+								Paths: []networkingv1.HTTPIngressPath{{
+									Backend: networkingv1.IngressBackend{
+										Service: &networkingv1.IngressServiceBackend{
+											Name: "service",
+											Port: networkingv1.ServiceBackendPort{Number: 443},
+										},
+									},
+								}},
+							},
+						},
+					}},
+				},
+			},
+			modify: func(obj client.Object) {
+				obj.GetAnnotations()["kubernetes.io/ingress.class"] = "nginx"
+			},
+		},
+		{
+			name: "ingress loses pomerium annotations",
+			obj: &networkingv1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "ingress",
+					Namespace: "test",
+					Annotations: map[string]string{
+						"ingress.pomerium.io/allowed_groups": `["foo","bar"]`,
+						"kubernetes.io/ingress.class":        "pomerium",
+					},
+				},
+				Spec: networkingv1.IngressSpec{
+					Rules: []networkingv1.IngressRule{{
+						Host: "test.lan.beyondcorp.org",
+						IngressRuleValue: networkingv1.IngressRuleValue{
+							HTTP: &networkingv1.HTTPIngressRuleValue{
+								Paths: []networkingv1.HTTPIngressPath{{
+									Backend: networkingv1.IngressBackend{
+										Service: &networkingv1.IngressServiceBackend{
+											Name: "service",
+											Port: networkingv1.ServiceBackendPort{Number: 443},
+										},
+									},
+								}},
+							},
+						},
+					}},
+				},
+			},
+			modify: func(obj client.Object) {
+				delete(obj.GetAnnotations(), "ingress.pomerium.io/allowed_groups")
+			},
+		},
+		{
+			name: "service changes class",
+			obj: &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "service",
+					Namespace: "test",
+					Annotations: map[string]string{
+						"ingress.pomerium.io/allowed_groups": `["foo","bar"]`,
+						"ingress.pomerium.io/from":           `https://test.lan.beyondcorp.org`,
+						"kubernetes.io/service.class":        "pomerium",
+					},
+				},
+				Spec: corev1.ServiceSpec{
+					Ports: []corev1.ServicePort{{Name: "https", Port: 443}},
+				},
+			},
+			modify: func(obj client.Object) {
+				obj.GetAnnotations()["kubernetes.io/service.class"] = "other"
+			},
+		},
+		{
+			name: "service policy becomes invalid",
+			obj: &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "service",
+					Namespace: "test",
+					Annotations: map[string]string{
+						"ingress.pomerium.io/allowed_groups": `["foo","bar"]`,
+						"ingress.pomerium.io/from":           `https://test.lan.beyondcorp.org`,
+						"kubernetes.io/service.class":        "pomerium",
+					},
+				},
+				Spec: corev1.ServiceSpec{
+					Ports: []corev1.ServicePort{{Name: "https", Port: 443}},
+				},
+			},
+			modify: func(obj client.Object) {
+				delete(obj.GetAnnotations(), "ingress.pomerium.io/from")
+			},
+		},
+		{
+			name: "service annotation becomes invalid",
+			obj: &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "service",
+					Namespace: "test",
+					Annotations: map[string]string{
+						"ingress.pomerium.io/allowed_groups": `["foo","bar"]`,
+						"ingress.pomerium.io/from":           `https://test.lan.beyondcorp.org`,
+						"kubernetes.io/service.class":        "pomerium",
+					},
+				},
+				Spec: corev1.ServiceSpec{
+					Ports: []corev1.ServicePort{{Name: "https", Port: 443}},
+				},
+			},
+			modify: func(obj client.Object) {
+				obj.GetAnnotations()["ingress.pomerium.io/allowed_groups"] = `["foo","bar"`
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(tt.obj)}
+			c := fake.NewFakeClient(tt.obj.DeepCopyObject())
+			cm := configmanager.NewConfigManager("test", "test", c, time.Nanosecond*1)
+			r := NewReconciler(tt.obj.DeepCopyObject(), "pomerium", cm)
+			assert.NoError(t, r.InjectClient(c))
+
+			currentPolicies := func() []pomeriumconfig.Policy {
+				options, err := cm.GetCurrentConfig()
+				assert.NoError(t, err)
+				return options.Policies
+			}
+
+			_, err := r.Reconcile(context.Background(), request)
+			assert.NoError(t, err)
+			assert.NotEmpty(t, currentPolicies())
+
+			current := tt.obj.DeepCopyObject().(client.Object)
+			assert.NoError(t, c.Get(context.Background(), request.NamespacedName, current))
+			tt.modify(current)
+			assert.NoError(t, c.Update(context.Background(), current))
+
+			_, err = r.Reconcile(context.Background(), request)
+			assert.NoError(t, err)
+			assert.Empty(t, currentPolicies())
+		})
+	}
+}
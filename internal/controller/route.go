@@ -0,0 +1,284 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/pomerium/pomerium-operator/api/v1alpha1"
+	"github.com/pomerium/pomerium-operator/internal/configmanager"
+	pomeriumconfig "github.com/pomerium/pomerium/config"
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// RouteReconciler implements a Kubernetes reconciler for PomeriumRoute resources.  Use NewRouteReconciler() to initialize.
+//
+// Namespace, address and event settings are shared with Reconciler.  The v1alpha1 types must be registered with the
+// client-go scheme.
+type RouteReconciler struct {
+	*Reconciler
+}
+
+// NewRouteReconciler returns a new RouteReconciler which adds a policy to configManager for every accepted PomeriumRoute
+func NewRouteReconciler(configManager *configmanager.ConfigManager) *RouteReconciler {
+	return &RouteReconciler{Reconciler: NewReconciler(&v1alpha1.PomeriumRoute{}, "", configManager)}
+}
+
+// routeResourceIdentifier returns the ResourceIdentifier a PomeriumRoute's policy is stored under
+func routeResourceIdentifier(name types.NamespacedName) configmanager.ResourceIdentifier {
+	return configmanager.ResourceIdentifier{
+		GVK:            v1alpha1.GroupVersion.WithKind("PomeriumRoute"),
+		NamespacedName: name,
+	}
+}
+
+// Reconcile implements the Reconciler interface for PomeriumRoutes.  The Accepted condition of the route reports the result.
+//
+// Routes with an invalid policy are removed.  If a backend cannot be resolved the existing route is left in place and the
+// request is retried.
+func (r *RouteReconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	logger.V(1).Info("notified of change to route", "resource", req.NamespacedName)
+	resource := routeResourceIdentifier(req.NamespacedName)
+
+	route := &v1alpha1.PomeriumRoute{}
+	if err := r.Get(ctx, req.NamespacedName, route); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return reconcile.Result{}, fmt.Errorf("could not get route %s: %w", req.NamespacedName, err)
+		}
+
+		logger.V(1).Info("route deleted", "resource", resource)
+		r.RemoveRoute(resource)
+		clearDeniedHosts(resource)
+		return reconcile.Result{}, nil
+	}
+
+	match, err := r.namespaceMatch(ctx, route.Namespace)
+	if err != nil {
+		return reconcile.Result{}, fmt.Errorf("could not determine if namespace of %s is watched: %w", req.NamespacedName, err)
+	}
+	if !match {
+		logger.V(1).Info("route is not in a watched namespace", "resource", resource)
+		r.RemoveRoute(resource)
+		return reconcile.Result{}, nil
+	}
+
+	policies, reason, err := r.policiesFromRoute(ctx, route)
+	changed := false
+	if err == nil {
+		meta, metaErr := routeMetaFromObj(route)
+		if metaErr != nil {
+			reason, err = reasonInvalidAnnotation, fmt.Errorf("invalid %s annotation: %w", priorityAnnotation, metaErr)
+		} else {
+			changed = r.configManager.SetWithMeta(resource, policies, meta)
+		}
+	}
+
+	switch {
+	case err == nil:
+		if changed {
+			r.event(route, corev1.EventTypeNormal, reasonRouteAccepted, "accepted pomerium route from %q", route.Spec.From)
+		}
+		return reconcile.Result{}, r.updateRouteStatus(ctx, route, metav1.ConditionTrue, reasonRouteAccepted, "route accepted")
+	case reason == reasonUnresolvableBackend:
+		r.event(route, corev1.EventTypeWarning, reason, "%s", err)
+		if statusErr := r.updateRouteStatus(ctx, route, metav1.ConditionFalse, reason, err.Error()); statusErr != nil {
+			return reconcile.Result{}, statusErr
+		}
+		return reconcile.Result{}, fmt.Errorf("could not generate policy from %s: %w", req.NamespacedName, err)
+	case reason == "":
+		return reconcile.Result{}, fmt.Errorf("could not generate policy from %s: %w", req.NamespacedName, err)
+	default:
+		r.RemoveRoute(resource)
+		r.event(route, corev1.EventTypeWarning, reason, "%s", err)
+		return reconcile.Result{}, r.updateRouteStatus(ctx, route, metav1.ConditionFalse, reason, err.Error())
+	}
+}
+
+// policiesFromRoute returns the pomerium policies described by route, one for each upstream.  On failure, the event reason describing the
+// problem is returned with the error, or an empty reason if the route should be retried unchanged.
+func (r *RouteReconciler) policiesFromRoute(ctx context.Context, route *v1alpha1.PomeriumRoute) ([]pomeriumconfig.Policy, string, error) {
+	policy := pomeriumconfig.Policy{}
+
+	options, err := routePolicyOptions(route.Spec)
+	if err != nil {
+		return nil, reasonInvalidPolicy, err
+	}
+	if err := yaml.Unmarshal(options, &policy); err != nil {
+		return nil, reasonInvalidPolicy, fmt.Errorf("could not apply options to policy: %w", err)
+	}
+
+	optionValues := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(options, &optionValues); err != nil {
+		return nil, reasonInvalidPolicy, fmt.Errorf("could not read options: %w", err)
+	}
+	policyOptions := make(map[string]string, len(optionValues))
+	for k, v := range optionValues {
+		policyOptions[k] = string(v)
+	}
+	forbidden, err := r.forbiddenPolicyOptions(ctx, route.Namespace, policyOptions)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(forbidden) > 0 {
+		return nil, reasonForbiddenPolicy, fmt.Errorf("policy rejected by policy rules: %s", strings.Join(forbidden, "; "))
+	}
+
+	hostRules, err := r.loadHostRules(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	permitted, err := r.hostPermitted(ctx, hostRules, route.Namespace, route.Spec.From)
+	if err != nil {
+		return nil, "", err
+	}
+	if !permitted {
+		r.setDeniedHosts(route, 1)
+		return nil, reasonForbiddenHost, fmt.Errorf("host of %q is not permitted in namespace %s", route.Spec.From, route.Namespace)
+	}
+	r.setDeniedHosts(route, 0)
+
+	strategy, err := r.addressStrategyFor(route)
+	if err != nil {
+		return nil, reasonInvalidAnnotation, fmt.Errorf("invalid %s annotation: %w", addressStrategyAnnotation, err)
+	}
+	upstreamHost, err := upstreamHostFor(route)
+	if err != nil {
+		return nil, reasonInvalidAnnotation, fmt.Errorf("invalid %s annotation: %w", upstreamHostAnnotation, err)
+	}
+
+	scheme := strings.ToLower(route.Spec.BackendProtocol)
+	if scheme == "" {
+		scheme = "http"
+	}
+
+	// A Pomerium policy has a single upstream and only the first of several policies for a route is used
+	if len(route.Spec.To) != 1 {
+		return nil, reasonInvalidPolicy, fmt.Errorf("route must have exactly one backend, found %d", len(route.Spec.To))
+	}
+	backend := route.Spec.To[0]
+	backendURL, err := r.serviceBackendToURL(backend.Service, route.Namespace, strategy)
+	if err != nil {
+		return nil, reasonUnresolvableBackend, fmt.Errorf("could not resolve backend %s: %w", backend.Service.Name, err)
+	}
+	if upstreamHost != "" {
+		backendURL = withUpstreamHost(backendURL, upstreamHost)
+	}
+
+	policy.From = route.Spec.From
+	policy.Path = route.Spec.Path
+	policy.Prefix = route.Spec.Prefix
+	policy.Regex = route.Spec.Regex
+	policy = policyTo(policy, scheme, backendURL)
+	if err := policy.Validate(); err != nil {
+		return nil, reasonInvalidPolicy, fmt.Errorf("invalid policy: %w", err)
+	}
+
+	return []pomeriumconfig.Policy{policy}, "", nil
+}
+
+// routePolicyOptions returns the policy options of spec in their configuration file form.  Typed fields override the
+// free form options.
+func routePolicyOptions(spec v1alpha1.PomeriumRouteSpec) ([]byte, error) {
+	options := make(map[string]interface{})
+	if spec.Options != nil && len(spec.Options.Raw) > 0 {
+		if err := json.Unmarshal(spec.Options.Raw, &options); err != nil {
+			return nil, fmt.Errorf("options must be an object: %w", err)
+		}
+	}
+
+	if len(spec.AllowedUsers) > 0 {
+		options["allowed_users"] = spec.AllowedUsers
+	}
+	if len(spec.AllowedGroups) > 0 {
+		options["allowed_groups"] = spec.AllowedGroups
+	}
+	if len(spec.AllowedDomains) > 0 {
+		options["allowed_domains"] = spec.AllowedDomains
+	}
+	if spec.AllowPublicUnauthenticatedAccess {
+		options["allow_public_unauthenticated_access"] = true
+	}
+	if spec.AllowAnyAuthenticatedUser {
+		options["allow_any_authenticated_user"] = true
+	}
+	if spec.PreserveHostHeader {
+		options["preserve_host_header"] = true
+	}
+	if len(spec.SetRequestHeaders) > 0 {
+		options["set_request_headers"] = spec.SetRequestHeaders
+	}
+
+	return json.Marshal(options)
+}
+
+// updateRouteStatus sets the Accepted condition of route, writing the status only when it changes
+func (r *RouteReconciler) updateRouteStatus(ctx context.Context, route *v1alpha1.PomeriumRoute, status metav1.ConditionStatus, reason string, message string) error {
+	original := route.Status.DeepCopy()
+
+	route.Status.ObservedGeneration = route.Generation
+	meta.SetStatusCondition(&route.Status.Conditions, metav1.Condition{
+		Type:               v1alpha1.RouteConditionAccepted,
+		Status:             status,
+		ObservedGeneration: route.Generation,
+		Reason:             reason,
+		Message:            message,
+	})
+
+	if reflect.DeepEqual(original, &route.Status) {
+		return nil
+	}
+
+	if err := r.Status().Update(ctx, route); err != nil {
+		return fmt.Errorf("could not update status of route %s/%s: %w", route.Namespace, route.Name, err)
+	}
+	return nil
+}
+
+// requestsForRoutes returns requests for the PomeriumRoutes in namespace selected by filter
+func (r *RouteReconciler) requestsForRoutes(namespace string, filter func(*v1alpha1.PomeriumRoute) bool) []reconcile.Request {
+	requests := make([]reconcile.Request, 0)
+
+	routes := &v1alpha1.PomeriumRouteList{}
+	if err := r.List(context.Background(), routes, client.InNamespace(namespace)); err != nil {
+		logger.Error(err, "could not list routes", "namespace", namespace)
+		return requests
+	}
+
+	for i := range routes.Items {
+		if filter(&routes.Items[i]) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&routes.Items[i])})
+		}
+	}
+	return requests
+}
+
+// routeReferencesService determines if route has a backend referencing the Service name
+func routeReferencesService(route *v1alpha1.PomeriumRoute, name string) bool {
+	for _, backend := range route.Spec.To {
+		if backend.Service.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// RequestsForService maps a Service onto requests for every PomeriumRoute with a backend referencing it
+func (r *RouteReconciler) RequestsForService(obj client.Object) []reconcile.Request {
+	return r.requestsForRoutes(obj.GetNamespace(), func(route *v1alpha1.PomeriumRoute) bool {
+		return routeReferencesService(route, obj.GetName())
+	})
+}
+
+// RequestsForNamespace maps a Namespace onto requests for every PomeriumRoute within it
+func (r *RouteReconciler) RequestsForNamespace(obj client.Object) []reconcile.Request {
+	return r.requestsForRoutes(obj.GetName(), func(*v1alpha1.PomeriumRoute) bool { return true })
+}
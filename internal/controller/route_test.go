@@ -0,0 +1,197 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pomerium/pomerium-operator/api/v1alpha1"
+	"github.com/pomerium/pomerium-operator/internal/configmanager"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func init() {
+	utilruntime.Must(v1alpha1.AddToScheme(scheme.Scheme))
+}
+
+func newTestRoute(name string, spec v1alpha1.PomeriumRouteSpec) *v1alpha1.PomeriumRoute {
+	return &v1alpha1.PomeriumRoute{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test", Generation: 1},
+		Spec:       spec,
+	}
+}
+
+func newTestRouteBackend(name string, port networkingv1.ServiceBackendPort) v1alpha1.RouteBackend {
+	return v1alpha1.RouteBackend{Service: networkingv1.IngressServiceBackend{Name: name, Port: port}}
+}
+
+func Test_RouteReconciler_Reconcile(t *testing.T) {
+	backendService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "backend", Namespace: "test"},
+		Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "http", Port: 8080}}},
+	}
+	otherService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "test"},
+		Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Port: 80}}},
+	}
+
+	tests := []struct {
+		name         string
+		route        *v1alpha1.PomeriumRoute
+		fakeObjs     []runtime.Object
+		wantPolicies int
+		wantStatus   metav1.ConditionStatus
+		wantReason   string
+		wantErr      bool
+	}{
+		{
+			name: "accepted",
+			route: newTestRoute("route", v1alpha1.PomeriumRouteSpec{
+				From:          "https://app.lan.beyondcorp.org",
+				To:            []v1alpha1.RouteBackend{newTestRouteBackend("backend", networkingv1.ServiceBackendPort{Name: "http"})},
+				Prefix:        "/app",
+				AllowedGroups: []string{"foo"},
+			}),
+			fakeObjs:     []runtime.Object{backendService},
+			wantPolicies: 1,
+			wantStatus:   metav1.ConditionTrue,
+			wantReason:   reasonRouteAccepted,
+		},
+		{
+			name: "multiple backends",
+			route: newTestRoute("route", v1alpha1.PomeriumRouteSpec{
+				From: "https://app.lan.beyondcorp.org",
+				To: []v1alpha1.RouteBackend{
+					newTestRouteBackend("backend", networkingv1.ServiceBackendPort{Name: "http"}),
+					newTestRouteBackend("other", networkingv1.ServiceBackendPort{Number: 80}),
+				},
+				AllowedGroups: []string{"foo"},
+			}),
+			fakeObjs:   []runtime.Object{backendService, otherService},
+			wantStatus: metav1.ConditionFalse,
+			wantReason: reasonInvalidPolicy,
+		},
+		{
+			name: "invalid policy",
+			route: newTestRoute("route", v1alpha1.PomeriumRouteSpec{
+				To:            []v1alpha1.RouteBackend{newTestRouteBackend("backend", networkingv1.ServiceBackendPort{Number: 8080})},
+				AllowedGroups: []string{"foo"},
+			}),
+			wantStatus: metav1.ConditionFalse,
+			wantReason: reasonInvalidPolicy,
+		},
+		{
+			name: "unresolvable backend",
+			route: newTestRoute("route", v1alpha1.PomeriumRouteSpec{
+				From:          "https://app.lan.beyondcorp.org",
+				To:            []v1alpha1.RouteBackend{newTestRouteBackend("missing", networkingv1.ServiceBackendPort{Name: "http"})},
+				AllowedGroups: []string{"foo"},
+			}),
+			wantStatus: metav1.ConditionFalse,
+			wantReason: reasonUnresolvableBackend,
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewFakeClient(append(tt.fakeObjs, tt.route)...)
+			cm := configmanager.NewConfigManager("test", "test", c, time.Nanosecond*1)
+			r := NewRouteReconciler(cm)
+			assert.NoError(t, r.InjectClient(c))
+
+			request := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(tt.route)}
+			_, err := r.Reconcile(context.Background(), request)
+			assert.Equal(t, tt.wantErr, err != nil)
+
+			options, err := cm.GetCurrentConfig()
+			assert.NoError(t, err)
+			assert.Len(t, options.Policies, tt.wantPolicies)
+
+			route := &v1alpha1.PomeriumRoute{}
+			assert.NoError(t, c.Get(context.Background(), request.NamespacedName, route))
+			condition := meta.FindStatusCondition(route.Status.Conditions, v1alpha1.RouteConditionAccepted)
+			if assert.NotNil(t, condition) {
+				assert.Equal(t, tt.wantStatus, condition.Status)
+				assert.Equal(t, tt.wantReason, condition.Reason)
+			}
+			assert.Equal(t, int64(1), route.Status.ObservedGeneration)
+
+			assert.NoError(t, c.Delete(context.Background(), route))
+			_, err = r.Reconcile(context.Background(), request)
+			assert.NoError(t, err)
+
+			options, err = cm.GetCurrentConfig()
+			assert.NoError(t, err)
+			assert.Empty(t, options.Policies)
+		})
+	}
+}
+
+func Test_RouteReconciler_policiesFromRoute(t *testing.T) {
+	c := fake.NewFakeClient()
+	r := NewRouteReconciler(configmanager.NewConfigManager("test", "test", c, time.Nanosecond*1))
+	assert.NoError(t, r.InjectClient(c))
+
+	route := newTestRoute("route", v1alpha1.PomeriumRouteSpec{
+		From:            "https://app.lan.beyondcorp.org",
+		To:              []v1alpha1.RouteBackend{newTestRouteBackend("a", networkingv1.ServiceBackendPort{Number: 80})},
+		BackendProtocol: "HTTPS",
+		Path:            "/exact",
+		AllowedUsers:    []string{"user@beyondcorp.org"},
+		Options:         &runtime.RawExtension{Raw: []byte(`{"allowed_users": ["other@beyondcorp.org"], "from": "https://ignored", "preserve_host_header": true}`)},
+	})
+
+	policies, reason, err := r.policiesFromRoute(context.Background(), route)
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	if assert.Len(t, policies, 1) {
+		policy := policies[0]
+		assert.Equal(t, "https://a.test.svc.cluster.local:80", policy.To)
+		assert.Equal(t, "https://app.lan.beyondcorp.org", policy.From)
+		assert.Equal(t, "/exact", policy.Path)
+		assert.Equal(t, []string{"user@beyondcorp.org"}, policy.AllowedUsers)
+
+		policyBytes, err := yaml.Marshal(policy)
+		assert.NoError(t, err)
+		assert.Contains(t, string(policyBytes), "preserve_host_header: true")
+	}
+
+	route.Spec.To = append(route.Spec.To, newTestRouteBackend("b", networkingv1.ServiceBackendPort{Number: 80}))
+	_, reason, err = r.policiesFromRoute(context.Background(), route)
+	assert.Error(t, err)
+	assert.Equal(t, reasonInvalidPolicy, reason)
+	route.Spec.To = route.Spec.To[:1]
+
+	route.Spec.Options = &runtime.RawExtension{Raw: []byte(`["not", "an", "object"]`)}
+	_, reason, err = r.policiesFromRoute(context.Background(), route)
+	assert.Error(t, err)
+	assert.Equal(t, reasonInvalidPolicy, reason)
+}
+
+func Test_RouteReconciler_RequestsForService(t *testing.T) {
+	c := fake.NewFakeClient(
+		newTestRoute("uses-backend", v1alpha1.PomeriumRouteSpec{To: []v1alpha1.RouteBackend{newTestRouteBackend("backend", networkingv1.ServiceBackendPort{Number: 80})}}),
+		newTestRoute("other", v1alpha1.PomeriumRouteSpec{To: []v1alpha1.RouteBackend{newTestRouteBackend("other", networkingv1.ServiceBackendPort{Number: 80})}}),
+	)
+	r := NewRouteReconciler(configmanager.NewConfigManager("test", "test", c, time.Nanosecond*1))
+	assert.NoError(t, r.InjectClient(c))
+
+	service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "backend", Namespace: "test"}}
+	assert.Equal(t, []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Name: "uses-backend", Namespace: "test"}},
+	}, r.RequestsForService(service))
+	assert.Len(t, r.RequestsForNamespace(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test"}}), 2)
+}
@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	gyaml "github.com/ghodss/yaml"
+	yaml "gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// secretRefSuffix marks a policy annotation whose value is read from a Secret in the namespace of the resource, e.g.
+// `ingress.pomerium.io/tls_client_key_secret: name/key` sets tls_client_key
+const secretRefSuffix = "_secret"
+
+// base64PolicyOptions are the policy options holding base64 encoded data.  Secret values are encoded for them.
+var base64PolicyOptions = map[string]bool{
+	"tls_client_cert":          true,
+	"tls_client_key":           true,
+	"tls_custom_ca":            true,
+	"tls_downstream_client_ca": true,
+}
+
+// secretKeyRef is a reference to a key of a Secret
+type secretKeyRef struct {
+	name string
+	key  string
+}
+
+// parseSecretRef parses a `name/key` Secret reference
+func parseSecretRef(value string) (secretKeyRef, error) {
+	parts := strings.Split(value, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return secretKeyRef{}, fmt.Errorf("%q is not in name/key form", value)
+	}
+	return secretKeyRef{name: parts[0], key: parts[1]}, nil
+}
+
+// secretRefOption returns the policy option set by a secret reference annotation key, if key is one
+func secretRefOption(key string) (string, bool) {
+	if !strings.HasPrefix(key, policyAnnotationPrefix) || !strings.HasSuffix(key, secretRefSuffix) {
+		return "", false
+	}
+	option := strings.TrimSuffix(strings.TrimPrefix(key, policyAnnotationPrefix), secretRefSuffix)
+	return option, option != ""
+}
+
+// secretRefNames returns the names of the Secrets referenced by the policy annotations in annotations
+func secretRefNames(annotations map[string]string) []string {
+	names := make([]string, 0)
+	for k, v := range annotations {
+		if _, ok := secretRefOption(k); !ok {
+			continue
+		}
+		if ref, err := parseSecretRef(v); err == nil {
+			names = append(names, ref.name)
+		}
+	}
+	return names
+}
+
+// secretRefValue returns the JSON value of option read from the Secret referenced by ref in namespace.
+//
+// Base64 options are set to the encoded Secret value.  Secret values holding a YAML or JSON map or list are used as
+// structured values, e.g. for set_request_headers.  Any other value is used as a string with surrounding whitespace
+// removed.
+func (r *Reconciler) secretRefValue(ctx context.Context, namespace string, option string, ref secretKeyRef) (string, error) {
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Name: ref.name, Namespace: namespace}, secret); err != nil {
+		return "", fmt.Errorf("could not get secret %s/%s: %w", namespace, ref.name, err)
+	}
+
+	data, ok := secret.Data[ref.key]
+	if !ok {
+		return "", validationError{fmt.Errorf("secret %s/%s has no key %q", namespace, ref.name, ref.key)}
+	}
+
+	if base64PolicyOptions[option] {
+		return jsonString(base64.StdEncoding.EncodeToString(data))
+	}
+
+	var structured interface{}
+	if err := yaml.Unmarshal(data, &structured); err == nil {
+		switch structured.(type) {
+		case map[interface{}]interface{}, []interface{}:
+			valueJSON, err := gyaml.YAMLToJSON(data)
+			if err != nil {
+				return "", validationError{fmt.Errorf("could not convert secret %s/%s key %q to JSON: %w", namespace, ref.name, ref.key, err)}
+			}
+			return string(valueJSON), nil
+		}
+	}
+
+	return jsonString(strings.TrimSpace(string(data)))
+}
+
+// jsonString returns value as a JSON string
+func jsonString(value string) (string, error) {
+	valueJSON, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(valueJSON), nil
+}
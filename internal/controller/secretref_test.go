@@ -0,0 +1,123 @@
+package controller
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/pomerium/pomerium-operator/internal/configmanager"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func newTestSecretRefService(name string, annotations map[string]string) *corev1.Service {
+	serviceAnnotations := map[string]string{
+		"ingress.pomerium.io/from":           "https://test.lan.beyondcorp.org",
+		"ingress.pomerium.io/allowed_groups": `["foo"]`,
+	}
+	for k, v := range annotations {
+		serviceAnnotations[k] = v
+	}
+
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test", Annotations: serviceAnnotations},
+		Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "https", Port: 443}}},
+	}
+}
+
+func newTestSecret(name string, data map[string]string) *corev1.Secret {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test"},
+		Data:       make(map[string][]byte),
+	}
+	for k, v := range data {
+		secret.Data[k] = []byte(v)
+	}
+	return secret
+}
+
+func Test_policyFromObj_secretRefs(t *testing.T) {
+	cert, key := newTestKeyPair(t, "client.lan.beyondcorp.org")
+	fakeObjs := []runtime.Object{
+		newTestSecret("client-tls", map[string]string{"tls.crt": string(cert), "tls.key": string(key)}),
+		newTestSecret("upstream", map[string]string{
+			"token":   "eyJhbGciOiJSUzI1NiJ9.payload.signature\n",
+			"headers": `{"Authorization": "Bearer secret-token"}`,
+		}),
+	}
+
+	r := &Reconciler{}
+	assert.NoError(t, r.InjectClient(fake.NewFakeClient(fakeObjs...)))
+
+	service := newTestSecretRefService("service", map[string]string{
+		"ingress.pomerium.io/tls_client_cert_secret":                  "client-tls/tls.crt",
+		"ingress.pomerium.io/tls_client_key_secret":                   "client-tls/tls.key",
+		"ingress.pomerium.io/kubernetes_service_account_token":        "inline-token",
+		"ingress.pomerium.io/kubernetes_service_account_token_secret": "upstream/token",
+		"ingress.pomerium.io/set_request_headers_secret":              "upstream/headers",
+	})
+	policies, err := r.policyFromObj(service)
+	if !assert.NoError(t, err) || !assert.Len(t, policies, 1) {
+		return
+	}
+
+	policyBytes, err := yaml.Marshal(policies[0])
+	assert.NoError(t, err)
+	policy := string(policyBytes)
+	assert.Contains(t, policy, "tls_client_cert: "+base64.StdEncoding.EncodeToString(cert))
+	assert.Contains(t, policy, "tls_client_key: "+base64.StdEncoding.EncodeToString(key))
+	assert.Contains(t, policy, "kubernetes_service_account_token: eyJhbGciOiJSUzI1NiJ9.payload.signature\n")
+	assert.Contains(t, policy, "Authorization: Bearer secret-token")
+	assert.NotContains(t, policy, "inline-token")
+	assert.NotContains(t, policy, "_secret")
+}
+
+func Test_policyFromObj_secretRefErrors(t *testing.T) {
+	r := &Reconciler{}
+	assert.NoError(t, r.InjectClient(fake.NewFakeClient(newTestSecret("upstream", map[string]string{"token": "token"}))))
+
+	policies, err := r.policyFromObj(newTestSecretRefService("invalid-ref", map[string]string{
+		"ingress.pomerium.io/kubernetes_service_account_token_secret": "upstream",
+	}))
+	assert.NoError(t, err, "an invalid reference cannot be fixed by retrying")
+	assert.Empty(t, policies)
+
+	_, err = r.policyFromObj(newTestSecretRefService("missing-secret", map[string]string{
+		"ingress.pomerium.io/kubernetes_service_account_token_secret": "missing/token",
+	}))
+	assert.Error(t, err)
+
+	_, err = r.policyFromObj(newTestSecretRefService("missing-key", map[string]string{
+		"ingress.pomerium.io/kubernetes_service_account_token_secret": "upstream/missing",
+	}))
+	assert.Error(t, err)
+}
+
+func Test_Reconciler_RequestsForSecret_secretRefs(t *testing.T) {
+	c := fake.NewFakeClient(
+		newTestSecretRefService("uses-secret", map[string]string{"ingress.pomerium.io/tls_custom_ca_secret": "rotated/ca.crt"}),
+		newTestSecretRefService("other-secret", map[string]string{"ingress.pomerium.io/tls_custom_ca_secret": "other/ca.crt"}),
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "defaults", Annotations: map[string]string{
+			"ingress.pomerium.io/tls_custom_ca_secret": "rotated/ca.crt",
+		}}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "defaulted", Namespace: "defaults"}},
+	)
+	r := NewReconciler(&corev1.Service{}, "pomerium", configmanager.NewConfigManager("test", "test", c, time.Nanosecond*1))
+	assert.NoError(t, r.InjectClient(c))
+
+	assert.Equal(t, []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Name: "uses-secret", Namespace: "test"}},
+	}, r.RequestsForSecret(newTestSecret("rotated", nil)))
+
+	defaultsSecret := newTestSecret("rotated", nil)
+	defaultsSecret.Namespace = "defaults"
+	assert.Equal(t, []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Name: "defaulted", Namespace: "defaults"}},
+	}, r.RequestsForSecret(defaultsSecret))
+}
@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"context"
+
+	networkingv1beta1 "k8s.io/api/extensions/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// BackendServiceIndex is the field index of the Service names referenced by an Ingress backend
+const BackendServiceIndex = "pomerium.io/backend-service"
+
+// IndexBackendServices implements a client.IndexerFunc returning the names of all Services referenced by the backends
+// of an Ingress
+func IndexBackendServices(obj client.Object) []string {
+	seen := make(map[string]bool)
+	names := make([]string, 0)
+	add := func(name string) {
+		if name == "" || seen[name] {
+			return
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+
+	switch ingress := obj.(type) {
+	case *networkingv1.Ingress:
+		if ingress.Spec.DefaultBackend != nil && ingress.Spec.DefaultBackend.Service != nil {
+			add(ingress.Spec.DefaultBackend.Service.Name)
+		}
+		for _, rule := range ingress.Spec.Rules {
+			if rule.HTTP == nil {
+				continue
+			}
+			for _, path := range rule.HTTP.Paths {
+				if path.Backend.Service != nil {
+					add(path.Backend.Service.Name)
+				}
+			}
+		}
+	case *networkingv1beta1.Ingress:
+		if ingress.Spec.Backend != nil {
+			add(ingress.Spec.Backend.ServiceName)
+		}
+		for _, rule := range ingress.Spec.Rules {
+			if rule.HTTP == nil {
+				continue
+			}
+			for _, path := range rule.HTTP.Paths {
+				add(path.Backend.ServiceName)
+			}
+		}
+	}
+
+	return names
+}
+
+// RequestsForService maps a Service onto requests for every Ingress in its namespace with a backend referencing it, so
+// routes converge when Services are created or their ports change.  Changes to the publish Service are mapped as in
+// RequestsForPublishService.
+func (r *Reconciler) RequestsForService(obj client.Object) []reconcile.Request {
+	requests := r.RequestsForPublishService(obj)
+
+	ingresses, err := r.ingressesForService(client.ObjectKeyFromObject(obj))
+	if err != nil {
+		logger.Error(err, "could not list ingresses for service", "service", client.ObjectKeyFromObject(obj))
+		return requests
+	}
+
+	for _, ingress := range ingresses {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(ingress)})
+	}
+	return requests
+}
+
+// ingressesForService returns every Ingress with a backend referencing service
+func (r *Reconciler) ingressesForService(service types.NamespacedName) ([]client.Object, error) {
+	ingresses, err := r.listIngresses(context.Background(),
+		client.InNamespace(service.Namespace),
+		client.MatchingFields{BackendServiceIndex: service.Name},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := make([]client.Object, 0)
+	for _, ingress := range ingresses {
+		for _, name := range IndexBackendServices(ingress) {
+			if name == service.Name {
+				matched = append(matched, ingress)
+				break
+			}
+		}
+	}
+	return matched, nil
+}
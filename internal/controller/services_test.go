@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pomerium/pomerium-operator/internal/configmanager"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1beta1 "k8s.io/api/extensions/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func newTestBackendIngress(name string, namespace string, services ...string) *networkingv1.Ingress {
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+	}
+
+	paths := make([]networkingv1.HTTPIngressPath, 0)
+	for _, service := range services {
+		paths = append(paths, networkingv1.HTTPIngressPath{
+			Backend: networkingv1.IngressBackend{
+				Service: &networkingv1.IngressServiceBackend{
+					Name: service,
+					Port: networkingv1.ServiceBackendPort{Name: "https"},
+				},
+			},
+		})
+	}
+	ingress.Spec.Rules = []networkingv1.IngressRule{{
+		Host:             "test.lan.beyondcorp.org",
+		IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{Paths: paths}},
+	}}
+	return ingress
+}
+
+func Test_IndexBackendServices(t *testing.T) {
+	tests := []struct {
+		name string
+		obj  client.Object
+		want []string
+	}{
+		{
+			name: "ingress-v1",
+			obj: func() client.Object {
+				o := newTestBackendIngress("test", "test", "a", "b", "a")
+				o.Spec.DefaultBackend = &networkingv1.IngressBackend{
+					Service: &networkingv1.IngressServiceBackend{Name: "default"},
+				}
+				o.Spec.Rules = append(o.Spec.Rules, networkingv1.IngressRule{Host: "no-http.lan.beyondcorp.org"})
+				return o
+			}(),
+			want: []string{"default", "a", "b"},
+		},
+		{
+			name: "ingress-v1beta1",
+			obj: &networkingv1beta1.Ingress{
+				Spec: networkingv1beta1.IngressSpec{
+					Backend: &networkingv1beta1.IngressBackend{ServiceName: "default", ServicePort: intstr.FromInt(80)},
+					Rules: []networkingv1beta1.IngressRule{{
+						IngressRuleValue: networkingv1beta1.IngressRuleValue{
+							HTTP: &networkingv1beta1.HTTPIngressRuleValue{
+								Paths: []networkingv1beta1.HTTPIngressPath{
+									{Backend: networkingv1beta1.IngressBackend{ServiceName: "a", ServicePort: intstr.FromString("https")}},
+								},
+							},
+						},
+					}},
+				},
+			},
+			want: []string{"default", "a"},
+		},
+		{
+			name: "service",
+			obj:  &corev1.Service{},
+			want: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IndexBackendServices(tt.obj))
+		})
+	}
+}
+
+func Test_Reconciler_RequestsForService(t *testing.T) {
+	c := fake.NewFakeClient(
+		newTestBackendIngress("uses-service", "test", "other", "backend"),
+		newTestBackendIngress("other-service", "test", "other"),
+		newTestBackendIngress("other-namespace", "other", "backend"),
+	)
+	r := NewReconciler(&networkingv1.Ingress{}, "pomerium", configmanager.NewConfigManager("test", "test", c, time.Nanosecond*1))
+	assert.NoError(t, r.InjectClient(c))
+
+	service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "backend", Namespace: "test"}}
+	assert.Equal(t, []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Name: "uses-service", Namespace: "test"}},
+	}, r.RequestsForService(service))
+}
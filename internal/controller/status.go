@@ -0,0 +1,158 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1beta1 "k8s.io/api/extensions/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// SetPublishService enables publishing the address of service into the status of accepted Ingresses
+func (r *Reconciler) SetPublishService(service types.NamespacedName) {
+	r.publishService = service
+}
+
+// SetPublishAddresses enables publishing static addresses into the status of accepted Ingresses.  Each address may
+// be an IP or a hostname.  Static addresses take precedence over SetPublishService.
+func (r *Reconciler) SetPublishAddresses(addresses []string) {
+	r.publishAddresses = addresses
+}
+
+// publishEnabled determines if the Reconciler maintains Ingress status
+func (r *Reconciler) publishEnabled() bool {
+	return len(r.publishAddresses) > 0 || r.publishService.Name != ""
+}
+
+// publishedStatus returns the load balancer status to publish into accepted Ingresses
+func (r *Reconciler) publishedStatus(ctx context.Context) ([]corev1.LoadBalancerIngress, error) {
+	lbIngress := make([]corev1.LoadBalancerIngress, 0)
+
+	if len(r.publishAddresses) > 0 {
+		for _, address := range r.publishAddresses {
+			lbIngress = append(lbIngress, loadBalancerIngressFromAddress(address))
+		}
+		return sortLoadBalancerIngress(lbIngress), nil
+	}
+
+	service := &corev1.Service{}
+	if err := r.Get(ctx, r.publishService, service); err != nil {
+		return nil, fmt.Errorf("could not get publish service %s: %w", r.publishService, err)
+	}
+
+	switch {
+	case service.Spec.Type == corev1.ServiceTypeLoadBalancer:
+		lbIngress = append(lbIngress, service.Status.LoadBalancer.Ingress...)
+	case len(service.Spec.ExternalIPs) > 0:
+		for _, ip := range service.Spec.ExternalIPs {
+			lbIngress = append(lbIngress, corev1.LoadBalancerIngress{IP: ip})
+		}
+	case service.Spec.ClusterIP != "" && service.Spec.ClusterIP != corev1.ClusterIPNone:
+		lbIngress = append(lbIngress, corev1.LoadBalancerIngress{IP: service.Spec.ClusterIP})
+	}
+
+	return sortLoadBalancerIngress(lbIngress), nil
+}
+
+// updateIngressStatus reconciles the status of an Ingress with the configured address.  An accepted Ingress publishes
+// the current address, and any other Ingress claimed by this Reconciler publishes none, so stale addresses are
+// replaced or cleared.  An Ingress which is not claimed, e.g. of another class, only has the current address cleared,
+// as addresses set by other controllers are left in place.
+func (r *Reconciler) updateIngressStatus(ctx context.Context, obj client.Object, claimed bool, accepted bool) error {
+	if !r.publishEnabled() {
+		return nil
+	}
+
+	status := ingressLoadBalancerStatus(obj)
+	if status == nil {
+		return nil
+	}
+
+	published, err := r.publishedStatus(ctx)
+	if err != nil {
+		return err
+	}
+
+	current := sortLoadBalancerIngress(status.Ingress)
+	var desired []corev1.LoadBalancerIngress
+	switch {
+	case accepted:
+		desired = published
+	case !claimed && !apiequality.Semantic.DeepEqual(current, published):
+		return nil
+	}
+
+	if apiequality.Semantic.DeepEqual(current, desired) {
+		return nil
+	}
+
+	logger.V(1).Info("updating ingress status", "ingress", client.ObjectKeyFromObject(obj), "addresses", desired)
+	status.Ingress = desired
+	if err := r.Status().Update(ctx, obj); err != nil {
+		return fmt.Errorf("could not update ingress status: %w", err)
+	}
+	return nil
+}
+
+// RequestsForPublishService maps the publish Service onto requests for every Ingress so their status is refreshed when
+// its address changes
+func (r *Reconciler) RequestsForPublishService(obj client.Object) []reconcile.Request {
+	if len(r.publishAddresses) > 0 || client.ObjectKeyFromObject(obj) != r.publishService {
+		return nil
+	}
+
+	ingresses, err := r.listIngresses(context.Background())
+	if err != nil {
+		logger.Error(err, "could not list ingresses for publish service", "service", r.publishService)
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(ingresses))
+	for _, ingress := range ingresses {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(ingress)})
+	}
+	return requests
+}
+
+// ingressLoadBalancerStatus returns a pointer to the load balancer status of an Ingress, or nil if obj is not an Ingress
+func ingressLoadBalancerStatus(obj client.Object) *corev1.LoadBalancerStatus {
+	switch ingress := obj.(type) {
+	case *networkingv1.Ingress:
+		return &ingress.Status.LoadBalancer
+	case *networkingv1beta1.Ingress:
+		return &ingress.Status.LoadBalancer
+	}
+	return nil
+}
+
+// loadBalancerIngressFromAddress converts an IP or hostname into a LoadBalancerIngress
+func loadBalancerIngressFromAddress(address string) corev1.LoadBalancerIngress {
+	if net.ParseIP(address) != nil {
+		return corev1.LoadBalancerIngress{IP: address}
+	}
+	return corev1.LoadBalancerIngress{Hostname: address}
+}
+
+// sortLoadBalancerIngress returns a sorted copy of lbIngress, or nil if it is empty
+func sortLoadBalancerIngress(lbIngress []corev1.LoadBalancerIngress) []corev1.LoadBalancerIngress {
+	if len(lbIngress) == 0 {
+		return nil
+	}
+
+	sorted := make([]corev1.LoadBalancerIngress, len(lbIngress))
+	copy(sorted, lbIngress)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].IP != sorted[j].IP {
+			return sorted[i].IP < sorted[j].IP
+		}
+		return sorted[i].Hostname < sorted[j].Hostname
+	})
+	return sorted
+}
@@ -0,0 +1,140 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pomerium/pomerium-operator/internal/configmanager"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var testPublishService = types.NamespacedName{Name: "pomerium-proxy", Namespace: "pomerium"}
+
+func newTestPublishService(spec corev1.ServiceSpec, status corev1.ServiceStatus) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: testPublishService.Name, Namespace: testPublishService.Namespace},
+		Spec:       spec,
+		Status:     status,
+	}
+}
+
+func Test_publishedStatus(t *testing.T) {
+	tests := []struct {
+		name             string
+		publishAddresses []string
+		fakeObjs         []runtime.Object
+		want             []corev1.LoadBalancerIngress
+		wantErr          bool
+	}{
+		{
+			name:             "static addresses",
+			publishAddresses: []string{"pomerium.beyondcorp.org", "10.0.0.1"},
+			want: []corev1.LoadBalancerIngress{
+				{Hostname: "pomerium.beyondcorp.org"},
+				{IP: "10.0.0.1"},
+			},
+		},
+		{
+			name: "load balancer service",
+			fakeObjs: []runtime.Object{newTestPublishService(
+				corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer, ClusterIP: "10.96.0.10"},
+				corev1.ServiceStatus{LoadBalancer: corev1.LoadBalancerStatus{Ingress: []corev1.LoadBalancerIngress{{IP: "203.0.113.10"}}}},
+			)},
+			want: []corev1.LoadBalancerIngress{{IP: "203.0.113.10"}},
+		},
+		{
+			name: "external ips",
+			fakeObjs: []runtime.Object{newTestPublishService(
+				corev1.ServiceSpec{Type: corev1.ServiceTypeClusterIP, ClusterIP: "10.96.0.10", ExternalIPs: []string{"203.0.113.11"}},
+				corev1.ServiceStatus{},
+			)},
+			want: []corev1.LoadBalancerIngress{{IP: "203.0.113.11"}},
+		},
+		{
+			name: "cluster ip",
+			fakeObjs: []runtime.Object{newTestPublishService(
+				corev1.ServiceSpec{Type: corev1.ServiceTypeClusterIP, ClusterIP: "10.96.0.10"},
+				corev1.ServiceStatus{},
+			)},
+			want: []corev1.LoadBalancerIngress{{IP: "10.96.0.10"}},
+		},
+		{
+			name:    "missing service",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewFakeClient(tt.fakeObjs...)
+			r := NewReconciler(&networkingv1.Ingress{}, "pomerium", configmanager.NewConfigManager("test", "test", c, time.Nanosecond*1))
+			assert.NoError(t, r.InjectClient(c))
+			r.SetPublishService(testPublishService)
+			r.SetPublishAddresses(tt.publishAddresses)
+
+			got, err := r.publishedStatus(context.Background())
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_updateIngressStatus(t *testing.T) {
+	published := []corev1.LoadBalancerIngress{{IP: "203.0.113.10"}}
+	foreign := []corev1.LoadBalancerIngress{{IP: "198.51.100.1"}}
+
+	tests := []struct {
+		name     string
+		current  []corev1.LoadBalancerIngress
+		claimed  bool
+		accepted bool
+		want     []corev1.LoadBalancerIngress
+	}{
+		{name: "publish accepted", claimed: true, accepted: true, want: published},
+		{name: "replace stale address", current: foreign, claimed: true, accepted: true, want: published},
+		{name: "clear when no longer accepted", current: published, claimed: true, accepted: false, want: nil},
+		{name: "clear stale address when no longer accepted", current: foreign, claimed: true, accepted: false, want: nil},
+		{name: "clear when no longer claimed", current: published, claimed: false, accepted: false, want: nil},
+		{name: "leave other controllers alone", current: foreign, claimed: false, accepted: false, want: foreign},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ingress := &networkingv1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test"},
+				Status:     networkingv1.IngressStatus{LoadBalancer: corev1.LoadBalancerStatus{Ingress: tt.current}},
+			}
+			c := fake.NewFakeClient(ingress)
+			r := NewReconciler(&networkingv1.Ingress{}, "pomerium", configmanager.NewConfigManager("test", "test", c, time.Nanosecond*1))
+			assert.NoError(t, r.InjectClient(c))
+			r.SetPublishAddresses([]string{"203.0.113.10"})
+
+			assert.NoError(t, r.updateIngressStatus(context.Background(), ingress, tt.claimed, tt.accepted))
+
+			updated := &networkingv1.Ingress{}
+			assert.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(ingress), updated))
+			assert.Equal(t, tt.want, updated.Status.LoadBalancer.Ingress)
+		})
+	}
+}
+
+func Test_Reconciler_RequestsForPublishService(t *testing.T) {
+	c := fake.NewFakeClient(newTestClassedIngress("a", "", nil), newTestClassedIngress("b", "", nil))
+	r := NewReconciler(&networkingv1.Ingress{}, "pomerium", configmanager.NewConfigManager("test", "test", c, time.Nanosecond*1))
+	assert.NoError(t, r.InjectClient(c))
+	r.SetPublishService(testPublishService)
+
+	assert.Len(t, r.RequestsForPublishService(newTestPublishService(corev1.ServiceSpec{}, corev1.ServiceStatus{})), 2)
+
+	other := newTestPublishService(corev1.ServiceSpec{}, corev1.ServiceStatus{})
+	other.Name = "other"
+	assert.Empty(t, r.RequestsForPublishService(other))
+}
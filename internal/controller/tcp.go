@@ -0,0 +1,120 @@
+package controller
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	pomeriumconfig "github.com/pomerium/pomerium/config"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// tcpPortsAnnotation lists the ports of a Service, by name or number, routed as TCP tunnels
+	tcpPortsAnnotation = "pomerium.ingress.kubernetes.io/tcp-ports"
+
+	// protocolTCP is the backend-protocol routing every port of a Service as a TCP tunnel
+	protocolTCP = "tcp"
+
+	tcpFromScheme = "tcp+https"
+)
+
+// tcpServicePorts returns the ports of service routed as TCP tunnels.  Every port is a TCP port if scheme is tcp,
+// otherwise only the ports listed in the tcp-ports annotation are.
+func tcpServicePorts(service *corev1.Service, scheme string) (map[int32]bool, error) {
+	ports := make(map[int32]bool)
+	if scheme == protocolTCP {
+		for _, port := range service.Spec.Ports {
+			ports[port.Port] = true
+		}
+		return ports, nil
+	}
+
+	value, ok := service.GetAnnotations()[tcpPortsAnnotation]
+	if !ok {
+		return ports, nil
+	}
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		found := false
+		for _, port := range service.Spec.Ports {
+			if port.Name == name || strconv.Itoa(int(port.Port)) == name {
+				ports[port.Port] = true
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("service has no port %q", name)
+		}
+	}
+	return ports, nil
+}
+
+// tcpFromPlaceholder returns the `from` of a TCP route on port before its host is known.  It is completed by
+// tcpPolicyFrom once the `from` annotation has been applied.
+func tcpFromPlaceholder(port int32) string {
+	return fmt.Sprintf("%s://:%d", tcpFromScheme, port)
+}
+
+// tcpPolicyFrom returns the `from` of the TCP route on the port of placeholder for the host of from.  from is either
+// `https://host`, which tunnels the Service port, or `tcp+https://host:port` to choose the port.  A port can only be
+// chosen when tcpPorts, the number of ports routed over TCP, is 1, as the routes would otherwise share it.
+func tcpPolicyFrom(placeholder string, from string, tcpPorts int) (string, error) {
+	if from == placeholder {
+		return "", fmt.Errorf("TCP routes require a from host")
+	}
+
+	placeholderURL, err := url.Parse(placeholder)
+	if err != nil {
+		return "", err
+	}
+	fromURL, err := url.Parse(from)
+	if err != nil {
+		return "", fmt.Errorf("invalid from %q: %w", from, err)
+	}
+	if fromURL.Hostname() == "" {
+		return "", fmt.Errorf("from %q has no host", from)
+	}
+	if (fromURL.Path != "" && fromURL.Path != "/") || fromURL.RawQuery != "" {
+		return "", fmt.Errorf("from %q of a TCP route cannot have a path", from)
+	}
+
+	port := placeholderURL.Port()
+	switch fromURL.Scheme {
+	case "https":
+		if fromURL.Port() != "" {
+			return "", fmt.Errorf("from %q of a TCP route must use the %s scheme to set a port", from, tcpFromScheme)
+		}
+	case tcpFromScheme:
+		if fromURL.Port() != "" {
+			if tcpPorts > 1 {
+				return "", fmt.Errorf("from %q cannot set a port when %d ports are routed over TCP", from, tcpPorts)
+			}
+			port = fromURL.Port()
+		}
+	default:
+		return "", fmt.Errorf("from %q of a TCP route must use the https or %s scheme", from, tcpFromScheme)
+	}
+	return fmt.Sprintf("%s://%s", tcpFromScheme, net.JoinHostPort(fromURL.Hostname(), port)), nil
+}
+
+// setTCPPolicyFrom completes the `from` of a TCP route which was set to a placeholder before its annotations were
+// applied, with tcpPorts ports routed over TCP.  TCP routes cannot match paths.
+func setTCPPolicyFrom(policy *pomeriumconfig.Policy, placeholder string, tcpPorts int) error {
+	if policy.Path != "" || policy.Prefix != "" || policy.Regex != "" {
+		return fmt.Errorf("TCP routes cannot match paths")
+	}
+
+	from, err := tcpPolicyFrom(placeholder, policy.From, tcpPorts)
+	if err != nil {
+		return err
+	}
+	policy.From = from
+	return nil
+}
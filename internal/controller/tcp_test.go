@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"testing"
+
+	pomeriumconfig "github.com/pomerium/pomerium/config"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_tcpServicePorts(t *testing.T) {
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "test"},
+		Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{
+			{Name: "postgres", Port: 5432},
+			{Name: "ssh", Port: 22},
+			{Name: "http", Port: 80},
+		}},
+	}
+
+	ports, err := tcpServicePorts(service, "http")
+	assert.NoError(t, err)
+	assert.Empty(t, ports)
+
+	ports, err = tcpServicePorts(service, protocolTCP)
+	assert.NoError(t, err)
+	assert.Equal(t, map[int32]bool{5432: true, 22: true, 80: true}, ports)
+
+	service.Annotations = map[string]string{tcpPortsAnnotation: "postgres, 22"}
+	ports, err = tcpServicePorts(service, "http")
+	assert.NoError(t, err)
+	assert.Equal(t, map[int32]bool{5432: true, 22: true}, ports)
+
+	service.Annotations = map[string]string{tcpPortsAnnotation: "redis"}
+	_, err = tcpServicePorts(service, "http")
+	assert.Error(t, err)
+}
+
+func Test_tcpPolicyFrom(t *testing.T) {
+	tests := []struct {
+		name     string
+		from     string
+		wantFrom string
+		wantErr  bool
+	}{
+		{"https host", "https://db.lan.beyondcorp.org", "tcp+https://db.lan.beyondcorp.org:5432", false},
+		{"tcp host", "tcp+https://db.lan.beyondcorp.org", "tcp+https://db.lan.beyondcorp.org:5432", false},
+		{"tcp host and port", "tcp+https://db.lan.beyondcorp.org:15432", "tcp+https://db.lan.beyondcorp.org:15432", false},
+		{"no from", tcpFromPlaceholder(5432), "", true},
+		{"https port", "https://db.lan.beyondcorp.org:15432", "", true},
+		{"path", "https://db.lan.beyondcorp.org/db", "", true},
+		{"http", "http://db.lan.beyondcorp.org", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, err := tcpPolicyFrom(tcpFromPlaceholder(5432), tt.from, 1)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.wantFrom, from)
+		})
+	}
+
+	from, err := tcpPolicyFrom(tcpFromPlaceholder(5432), "https://db.lan.beyondcorp.org", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, "tcp+https://db.lan.beyondcorp.org:5432", from)
+
+	_, err = tcpPolicyFrom(tcpFromPlaceholder(5432), "tcp+https://db.lan.beyondcorp.org:15432", 2)
+	assert.Error(t, err, "a port chosen by from would be shared by every TCP port")
+}
+
+func Test_setTCPPolicyFrom(t *testing.T) {
+	policy := pomeriumconfig.Policy{From: "https://db.lan.beyondcorp.org"}
+	assert.NoError(t, setTCPPolicyFrom(&policy, tcpFromPlaceholder(22), 1))
+	assert.Equal(t, "tcp+https://db.lan.beyondcorp.org:22", policy.From)
+
+	policy = pomeriumconfig.Policy{From: "https://db.lan.beyondcorp.org", Prefix: "/ssh"}
+	assert.Error(t, setTCPPolicyFrom(&policy, tcpFromPlaceholder(22), 1))
+}
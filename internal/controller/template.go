@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	gyaml "github.com/ghodss/yaml"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// templateAnnotation names the policy template a resource uses
+const templateAnnotation = policyAnnotationPrefix + "template"
+
+// SetPolicyTemplates sets the ConfigMap holding policy templates.  Each key of the ConfigMap is a template name and its
+// value a YAML or JSON map of policy options, e.g. `allowed_groups` or `timeout`.
+func (r *Reconciler) SetPolicyTemplates(name types.NamespacedName) {
+	r.policyTemplates = name
+}
+
+// templatePolicyOptions returns the JSON policy option values of the template called name.
+//
+// Templates are shared by every namespace, so they may not read options from Secrets with `<option>_secret` keys.  If
+// the template does not exist or is invalid, an event is recorded against obj and false is returned.
+func (r *Reconciler) templatePolicyOptions(ctx context.Context, obj runtime.Object, name string) (map[string]string, bool, error) {
+	if r.policyTemplates.Name == "" {
+		r.event(obj, corev1.EventTypeWarning, reasonInvalidAnnotation, "policy template %q requested but no policy templates are configured", name)
+		return nil, false, nil
+	}
+
+	configMap := &corev1.ConfigMap{}
+	if err := r.Get(ctx, r.policyTemplates, configMap); err != nil {
+		if apierrors.IsNotFound(err) {
+			r.event(obj, corev1.EventTypeWarning, reasonInvalidAnnotation, "policy template ConfigMap %s does not exist", r.policyTemplates)
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("could not get policy templates %s: %w", r.policyTemplates, err)
+	}
+
+	template, ok := configMap.Data[name]
+	if !ok {
+		r.event(obj, corev1.EventTypeWarning, reasonInvalidAnnotation, "unknown policy template %q", name)
+		return nil, false, nil
+	}
+
+	templateJSON, err := gyaml.YAMLToJSON([]byte(template))
+	if err != nil {
+		r.event(obj, corev1.EventTypeWarning, reasonInvalidAnnotation, "policy template %q is not valid YAML or JSON: %s", name, err)
+		return nil, false, nil
+	}
+
+	values := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(templateJSON, &values); err != nil {
+		r.event(obj, corev1.EventTypeWarning, reasonInvalidAnnotation, "policy template %q is not a map of policy options: %s", name, err)
+		return nil, false, nil
+	}
+
+	options := make(map[string]string, len(values))
+	for k, v := range values {
+		if _, ok := secretRefOption(policyAnnotationPrefix + k); ok {
+			r.event(obj, corev1.EventTypeWarning, reasonInvalidAnnotation, "policy template %q may not read %s from a secret, use an %s%s annotation instead", name, k, policyAnnotationPrefix, k)
+			return nil, false, nil
+		}
+		options[k] = string(v)
+	}
+	return options, true, nil
+}
+
+// RequestsForConfigMap maps the policy templates ConfigMap onto requests for every resource using a template, either by
+// its own annotation or by its namespace's policy defaults.  The policy rules and host rules ConfigMaps are mapped onto
+// requests for every resource.
+func (r *Reconciler) RequestsForConfigMap(obj client.Object) []reconcile.Request {
+	requests := make([]reconcile.Request, 0)
+	key := client.ObjectKeyFromObject(obj)
+	if key != r.policyTemplates && key != r.policyRules && key != r.hostRules {
+		return requests
+	}
+
+	ctx := context.Background()
+	objs, err := r.listObjects(ctx, "")
+	if err != nil {
+		logger.Error(err, "could not list resources for policy configmap", "configmap", key)
+		return requests
+	}
+
+	if key == r.policyRules || key == r.hostRules {
+		for _, o := range objs {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(o)})
+		}
+		return requests
+	}
+
+	namespaceTemplates := make(map[string]bool)
+	for _, o := range objs {
+		if _, ok := o.GetAnnotations()[templateAnnotation]; !ok {
+			usesTemplate, seen := namespaceTemplates[o.GetNamespace()]
+			if !seen {
+				defaults, err := r.namespacePolicyDefaults(ctx, o.GetNamespace())
+				if err != nil {
+					logger.Error(err, "could not get namespace policy defaults", "namespace", o.GetNamespace())
+				}
+				_, usesTemplate = defaults[templateAnnotation]
+				namespaceTemplates[o.GetNamespace()] = usesTemplate
+			}
+			if !usesTemplate {
+				continue
+			}
+		}
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(o)})
+	}
+	return requests
+}
@@ -0,0 +1,162 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pomerium/pomerium-operator/internal/configmanager"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var testPolicyTemplates = types.NamespacedName{Name: "policy-templates", Namespace: "pomerium"}
+
+func newTestPolicyTemplates(templates map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: testPolicyTemplates.Name, Namespace: testPolicyTemplates.Namespace},
+		Data:       templates,
+	}
+}
+
+func newTestTemplateService(name string, namespace string, annotations map[string]string) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Annotations: annotations},
+		Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "https", Port: 443}}},
+	}
+}
+
+func Test_policyFromObj_template(t *testing.T) {
+	templates := newTestPolicyTemplates(map[string]string{
+		"corp-sso": "allowed_groups: [corp]\nallowed_domains: [corp.beyondcorp.org]",
+		"invalid":  "not a map",
+	})
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test", Annotations: map[string]string{
+		"ingress.pomerium.io/allowed_groups": `["namespace"]`,
+		"ingress.pomerium.io/allowed_users":  `["namespace@beyondcorp.org"]`,
+	}}}
+
+	tests := []struct {
+		name               string
+		annotations        map[string]string
+		policyTemplates    types.NamespacedName
+		wantPolicies       int
+		wantAllowedGroups  []string
+		wantAllowedUsers   []string
+		wantAllowedDomains []string
+	}{
+		{
+			name: "template overrides namespace defaults",
+			annotations: map[string]string{
+				"ingress.pomerium.io/from":     "https://test.lan.beyondcorp.org",
+				"ingress.pomerium.io/template": "corp-sso",
+			},
+			policyTemplates:    testPolicyTemplates,
+			wantPolicies:       1,
+			wantAllowedGroups:  []string{"corp"},
+			wantAllowedUsers:   []string{"namespace@beyondcorp.org"},
+			wantAllowedDomains: []string{"corp.beyondcorp.org"},
+		},
+		{
+			name: "annotations override template",
+			annotations: map[string]string{
+				"ingress.pomerium.io/from":            "https://test.lan.beyondcorp.org",
+				"ingress.pomerium.io/template":        "corp-sso",
+				"ingress.pomerium.io/allowed_domains": `["other.beyondcorp.org"]`,
+			},
+			policyTemplates:    testPolicyTemplates,
+			wantPolicies:       1,
+			wantAllowedGroups:  []string{"corp"},
+			wantAllowedUsers:   []string{"namespace@beyondcorp.org"},
+			wantAllowedDomains: []string{"other.beyondcorp.org"},
+		},
+		{
+			name: "unknown template",
+			annotations: map[string]string{
+				"ingress.pomerium.io/from":     "https://test.lan.beyondcorp.org",
+				"ingress.pomerium.io/template": "missing",
+			},
+			policyTemplates: testPolicyTemplates,
+		},
+		{
+			name: "invalid template",
+			annotations: map[string]string{
+				"ingress.pomerium.io/from":     "https://test.lan.beyondcorp.org",
+				"ingress.pomerium.io/template": "invalid",
+			},
+			policyTemplates: testPolicyTemplates,
+		},
+		{
+			name: "templates not configured",
+			annotations: map[string]string{
+				"ingress.pomerium.io/from":     "https://test.lan.beyondcorp.org",
+				"ingress.pomerium.io/template": "corp-sso",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Reconciler{}
+			assert.NoError(t, r.InjectClient(fake.NewFakeClient(templates, namespace)))
+			r.SetPolicyTemplates(tt.policyTemplates)
+
+			policies, err := r.policyFromObj(newTestTemplateService("service", "test", tt.annotations))
+			assert.NoError(t, err)
+			if !assert.Len(t, policies, tt.wantPolicies) || tt.wantPolicies == 0 {
+				return
+			}
+			assert.Equal(t, tt.wantAllowedGroups, policies[0].AllowedGroups)
+			assert.Equal(t, tt.wantAllowedUsers, policies[0].AllowedUsers)
+			assert.Equal(t, tt.wantAllowedDomains, policies[0].AllowedDomains)
+		})
+	}
+}
+
+func Test_policyFromObj_templateSecretRef(t *testing.T) {
+	templates := newTestPolicyTemplates(map[string]string{
+		"client-tls": "tls_client_key_secret: client-tls/tls.key",
+	})
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "client-tls", Namespace: "test"},
+		Data:       map[string][]byte{"tls.key": []byte("key")},
+	}
+
+	r := &Reconciler{}
+	assert.NoError(t, r.InjectClient(fake.NewFakeClient(templates, secret)))
+	r.SetPolicyTemplates(testPolicyTemplates)
+
+	policies, err := r.policyFromObj(newTestTemplateService("service", "test", map[string]string{
+		"ingress.pomerium.io/from":     "https://test.lan.beyondcorp.org",
+		"ingress.pomerium.io/template": "client-tls",
+	}))
+	assert.NoError(t, err)
+	assert.Empty(t, policies)
+}
+
+func Test_Reconciler_RequestsForConfigMap(t *testing.T) {
+	c := fake.NewFakeClient(
+		newTestPolicyTemplates(nil),
+		newTestTemplateService("uses-template", "test", map[string]string{"ingress.pomerium.io/template": "corp-sso"}),
+		newTestTemplateService("no-template", "test", map[string]string{"ingress.pomerium.io/from": "https://test.lan.beyondcorp.org"}),
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "defaults", Annotations: map[string]string{
+			"ingress.pomerium.io/template": "corp-sso",
+		}}},
+		newTestTemplateService("defaulted", "defaults", nil),
+	)
+	r := NewReconciler(&corev1.Service{}, "pomerium", configmanager.NewConfigManager("test", "test", c, time.Nanosecond*1))
+	assert.NoError(t, r.InjectClient(c))
+	r.SetPolicyTemplates(testPolicyTemplates)
+
+	assert.ElementsMatch(t, []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Name: "uses-template", Namespace: "test"}},
+		{NamespacedName: types.NamespacedName{Name: "defaulted", Namespace: "defaults"}},
+	}, r.RequestsForConfigMap(newTestPolicyTemplates(nil)))
+
+	other := newTestPolicyTemplates(nil)
+	other.Name = "other"
+	assert.Empty(t, r.RequestsForConfigMap(other))
+}
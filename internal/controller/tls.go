@@ -0,0 +1,115 @@
+package controller
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+
+	"github.com/pomerium/pomerium-operator/internal/configmanager"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1beta1 "k8s.io/api/extensions/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// certificatesFromObj returns the certificates held by the TLS Secrets referenced in an Ingress spec.tls.
+//
+// Secrets which are missing or do not hold a valid key pair are skipped so they do not affect other certificates.
+func (r *Reconciler) certificatesFromObj(ctx context.Context, obj client.Object) []configmanager.Certificate {
+	certs := make([]configmanager.Certificate, 0)
+
+	for _, secretName := range tlsSecretNames(obj) {
+		secretRef := types.NamespacedName{Name: secretName, Namespace: obj.GetNamespace()}
+		cert, err := r.certificateFromSecret(ctx, secretRef)
+		if err != nil {
+			logger.Info("skipping tls secret", "ingress", client.ObjectKeyFromObject(obj), "secret", secretRef, "error", err.Error())
+			r.event(obj, corev1.EventTypeWarning, reasonInvalidTLSSecret, "skipping tls secret %s: %s", secretName, err)
+			continue
+		}
+		certs = append(certs, cert)
+	}
+
+	return certs
+}
+
+// certificateFromSecret loads and validates the key pair held by a kubernetes.io/tls Secret
+func (r *Reconciler) certificateFromSecret(ctx context.Context, secretRef types.NamespacedName) (configmanager.Certificate, error) {
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, secretRef, secret); err != nil {
+		return configmanager.Certificate{}, fmt.Errorf("could not get tls secret %s: %w", secretRef, err)
+	}
+
+	cert := configmanager.Certificate{
+		Cert: secret.Data[corev1.TLSCertKey],
+		Key:  secret.Data[corev1.TLSPrivateKeyKey],
+	}
+
+	if _, err := tls.X509KeyPair(cert.Cert, cert.Key); err != nil {
+		return configmanager.Certificate{}, fmt.Errorf("invalid key pair in tls secret %s: %w", secretRef, err)
+	}
+
+	return cert, nil
+}
+
+// RequestsForSecret maps a Secret onto requests for every resource in its namespace which references it, either in an
+// Ingress spec.tls or in a secret reference annotation.  Secrets referenced by namespace policy defaults map onto every
+// resource in the namespace.
+func (r *Reconciler) RequestsForSecret(obj client.Object) []reconcile.Request {
+	ctx := context.Background()
+	objs, err := r.listObjects(ctx, obj.GetNamespace())
+	if err != nil {
+		logger.Error(err, "could not list resources for secret", "secret", obj.GetName())
+		return nil
+	}
+
+	namespaceDefaults, err := r.namespacePolicyDefaults(ctx, obj.GetNamespace())
+	if err != nil {
+		logger.Error(err, "could not get namespace policy defaults for secret", "secret", obj.GetName())
+		return nil
+	}
+	referencedByNamespace := containsString(secretRefNames(namespaceDefaults), obj.GetName())
+
+	requests := make([]reconcile.Request, 0)
+	for _, o := range objs {
+		if referencedByNamespace ||
+			containsString(tlsSecretNames(o), obj.GetName()) ||
+			containsString(secretRefNames(o.GetAnnotations()), obj.GetName()) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(o)})
+		}
+	}
+	return requests
+}
+
+// containsString determines if value is in values
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// tlsSecretNames returns the Secret names referenced in an Ingress spec.tls
+func tlsSecretNames(obj client.Object) []string {
+	names := make([]string, 0)
+
+	switch ingress := obj.(type) {
+	case *networkingv1.Ingress:
+		for _, ingressTLS := range ingress.Spec.TLS {
+			if ingressTLS.SecretName != "" {
+				names = append(names, ingressTLS.SecretName)
+			}
+		}
+	case *networkingv1beta1.Ingress:
+		for _, ingressTLS := range ingress.Spec.TLS {
+			if ingressTLS.SecretName != "" {
+				names = append(names, ingressTLS.SecretName)
+			}
+		}
+	}
+
+	return names
+}
@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/pomerium/pomerium-operator/internal/configmanager"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func newTestKeyPair(t *testing.T, host string) (certPEM []byte, keyPEM []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: host},
+		DNSNames:     []string{host},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM
+}
+
+func newTestTLSSecret(name string, cert []byte, key []byte) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test"},
+		Type:       corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       cert,
+			corev1.TLSPrivateKeyKey: key,
+		},
+	}
+}
+
+func newTestTLSIngress(name string, secretNames ...string) *networkingv1.Ingress {
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test"},
+	}
+	for _, secretName := range secretNames {
+		ingress.Spec.TLS = append(ingress.Spec.TLS, networkingv1.IngressTLS{
+			Hosts:      []string{"test.lan.beyondcorp.org"},
+			SecretName: secretName,
+		})
+	}
+	return ingress
+}
+
+func Test_certificatesFromObj(t *testing.T) {
+	cert, key := newTestKeyPair(t, "test.lan.beyondcorp.org")
+	otherCert, _ := newTestKeyPair(t, "other.lan.beyondcorp.org")
+
+	c := fake.NewFakeClient(
+		newTestTLSSecret("valid", cert, key),
+		newTestTLSSecret("mismatched", otherCert, key),
+		newTestTLSSecret("empty", nil, nil),
+	)
+	r := NewReconciler(&networkingv1.Ingress{}, "pomerium", configmanager.NewConfigManager("test", "test", c, time.Nanosecond*1))
+	assert.NoError(t, r.InjectClient(c))
+
+	tests := []struct {
+		name      string
+		ingress   *networkingv1.Ingress
+		wantCerts []configmanager.Certificate
+	}{
+		{
+			name:      "no tls",
+			ingress:   newTestTLSIngress("test"),
+			wantCerts: []configmanager.Certificate{},
+		},
+		{
+			name:      "valid secret",
+			ingress:   newTestTLSIngress("test", "valid"),
+			wantCerts: []configmanager.Certificate{{Cert: cert, Key: key}},
+		},
+		{
+			name:      "invalid secrets skipped",
+			ingress:   newTestTLSIngress("test", "missing", "mismatched", "empty", "valid"),
+			wantCerts: []configmanager.Certificate{{Cert: cert, Key: key}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			certs := r.certificatesFromObj(context.Background(), tt.ingress)
+			assert.Equal(t, tt.wantCerts, certs)
+		})
+	}
+}
+
+func Test_Reconciler_RequestsForSecret(t *testing.T) {
+	c := fake.NewFakeClient(
+		newTestTLSIngress("uses-secret", "other", "rotated"),
+		newTestTLSIngress("other-secret", "other"),
+		newTestTLSIngress("no-tls"),
+	)
+	r := NewReconciler(&networkingv1.Ingress{}, "pomerium", configmanager.NewConfigManager("test", "test", c, time.Nanosecond*1))
+	assert.NoError(t, r.InjectClient(c))
+
+	requests := r.RequestsForSecret(newTestTLSSecret("rotated", nil, nil))
+	assert.Equal(t, []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Name: "uses-secret", Namespace: "test"}},
+	}, requests)
+}
@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ValidatePolicy returns the problems the Reconciler would report as Warning events when generating routes for obj,
+// e.g. annotations which are not valid YAML or JSON or policies failing validation.  Resources outside the watched
+// namespaces or of another class have no problems.
+//
+// Backends and Secrets which cannot be resolved are not problems, as they may be created after obj.  An error is
+// returned if obj could not be validated.
+func (r *Reconciler) ValidatePolicy(ctx context.Context, obj client.Object) ([]string, error) {
+	match, err := r.namespaceMatch(ctx, obj.GetNamespace())
+	if err != nil || !match {
+		return nil, err
+	}
+
+	match, err = r.classMatch(ctx, obj)
+	if err != nil || !match {
+		return nil, err
+	}
+
+	// Validate with a copy of the Reconciler collecting problems instead of recording events
+	recorder := &problemRecorder{}
+	validator := *r
+	validator.recorder = recorder
+	validator.validating = true
+
+	if _, err := validator.policyFromObj(obj); err != nil && !recorder.unresolved {
+		return nil, fmt.Errorf("could not validate policy: %w", err)
+	}
+
+	if _, err := routeMetaFromObj(obj); err != nil {
+		recorder.problems = append(recorder.problems, fmt.Sprintf("invalid %s annotation: %s", priorityAnnotation, err))
+	}
+
+	return recorder.problems, nil
+}
+
+// problemRecorder implements record.EventRecorder and collects the messages of Warning events
+type problemRecorder struct {
+	problems   []string
+	unresolved bool
+}
+
+// Event implements record.EventRecorder
+func (p *problemRecorder) Event(_ runtime.Object, eventType, reason, message string) {
+	if eventType != corev1.EventTypeWarning {
+		return
+	}
+	switch reason {
+	case reasonUnresolvableBackend, reasonUnresolvableSecret:
+		p.unresolved = true
+	default:
+		p.problems = append(p.problems, message)
+	}
+}
+
+// Eventf implements record.EventRecorder
+func (p *problemRecorder) Eventf(obj runtime.Object, eventType, reason, messageFmt string, args ...interface{}) {
+	p.Event(obj, eventType, reason, fmt.Sprintf(messageFmt, args...))
+}
+
+// AnnotatedEventf implements record.EventRecorder
+func (p *problemRecorder) AnnotatedEventf(obj runtime.Object, _ map[string]string, eventType, reason, messageFmt string, args ...interface{}) {
+	p.Eventf(obj, eventType, reason, messageFmt, args...)
+}
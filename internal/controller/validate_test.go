@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pomerium/pomerium-operator/internal/configmanager"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_ValidatePolicy(t *testing.T) {
+	tests := []struct {
+		name         string
+		annotations  map[string]string
+		wantProblems int
+	}{
+		{
+			name: "valid",
+			annotations: map[string]string{
+				"ingress.pomerium.io/from":           "https://test.lan.beyondcorp.org",
+				"ingress.pomerium.io/allowed_groups": `["foo"]`,
+			},
+		},
+		{
+			name: "invalid annotation",
+			annotations: map[string]string{
+				"ingress.pomerium.io/from":           "https://test.lan.beyondcorp.org",
+				"ingress.pomerium.io/allowed_groups": `["foo"`,
+			},
+			wantProblems: 1,
+		},
+		{
+			name:         "invalid policy",
+			annotations:  map[string]string{"ingress.pomerium.io/allowed_groups": `["foo"]`},
+			wantProblems: 1,
+		},
+		{
+			name: "invalid priority",
+			annotations: map[string]string{
+				"ingress.pomerium.io/from": "https://test.lan.beyondcorp.org",
+				priorityAnnotation:         "high",
+			},
+			wantProblems: 1,
+		},
+		{
+			name: "unresolvable secret",
+			annotations: map[string]string{
+				"ingress.pomerium.io/from":                  "https://test.lan.beyondcorp.org",
+				"ingress.pomerium.io/tls_client_key_secret": "missing/tls.key",
+			},
+		},
+		{
+			name: "other class",
+			annotations: map[string]string{
+				"kubernetes.io/service.class":        "other",
+				"ingress.pomerium.io/allowed_groups": `["foo"`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewFakeClient()
+			r := NewReconciler(&corev1.Service{}, "pomerium", configmanager.NewConfigManager("test", "test", c, time.Nanosecond*1))
+			assert.NoError(t, r.InjectClient(c))
+
+			service := &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{Name: "service", Namespace: "test", Annotations: tt.annotations},
+				Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "https", Port: 443}}},
+			}
+			problems, err := r.ValidatePolicy(context.Background(), service)
+			assert.NoError(t, err)
+			assert.Len(t, problems, tt.wantProblems, "problems: %v", problems)
+		})
+	}
+}
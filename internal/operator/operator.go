@@ -7,18 +7,26 @@ import (
 	"github.com/pomerium/pomerium-operator/internal/log"
 	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 var logger = log.L.WithValues("component", "operator")
 var zHandler = func(_ *http.Request) error { return nil }
 
 // Options represents the configuration of an Operator.  Used in NewOperator()
+//
+// A single entry in Namespaces restricts the cache of the Operator to that namespace.  With several entries the cache
+// remains cluster wide, as cluster scoped types such as IngressClass cannot be read through a multi-namespace cache, and
+// controllers are expected to filter resources by namespace themselves.
 type Options struct {
-	NameSpace               string
+	Namespaces              []string
 	ServiceClass            string
 	IngressClass            string
 	Secret                  string
@@ -30,6 +38,15 @@ type Options struct {
 	LeaderElection          bool
 	LeaderElectionID        string
 	LeaderElectionNamespace string
+	WebhookPort             int
+	WebhookCertDir          string
+}
+
+// Watch represents a secondary object type watched by a controller.  Events for Object are translated by Mapper into
+// requests for the controller's primary object type.
+type Watch struct {
+	Object client.Object
+	Mapper handler.MapFunc
 }
 
 // Operator is the high level wrapper around a manager and the group of controllers that represents the primary functionality of pomerium-operator.  Use NewOperator() to initialize.
@@ -46,7 +63,6 @@ type Operator struct {
 func NewOperator(opts Options) (*Operator, error) {
 
 	mgrOptions := manager.Options{
-		Namespace:               opts.NameSpace,
 		LeaderElection:          opts.LeaderElection,
 		LeaderElectionNamespace: opts.LeaderElectionNamespace,
 		LeaderElectionID:        opts.LeaderElectionID,
@@ -54,6 +70,12 @@ func NewOperator(opts Options) (*Operator, error) {
 		MapperProvider:          opts.MapperProvider,
 		MetricsBindAddress:      opts.MetricsBindAddress,
 		HealthProbeBindAddress:  opts.HealthAddress,
+		Port:                    opts.WebhookPort,
+		CertDir:                 opts.WebhookCertDir,
+	}
+
+	if len(opts.Namespaces) == 1 {
+		mgrOptions.Namespace = opts.Namespaces[0]
 	}
 
 	logger.V(1).Info("creating manager for operator")
@@ -100,11 +122,50 @@ func (o *Operator) Add(f manager.Runnable) error {
 	return o.mgr.Add(f)
 }
 
+// IndexField adds a field index over object types to the cache of the underlying controller-manager.  Indexes must be
+// added before the Operator is started.
+func (o *Operator) IndexField(object client.Object, field string, extractValue client.IndexerFunc) error {
+	return o.mgr.GetFieldIndexer().IndexField(context.Background(), object, field, extractValue)
+}
+
+// GetEventRecorderFor returns an EventRecorder for the underlying controller-manager, identifying events as coming from name
+func (o *Operator) GetEventRecorderFor(name string) record.EventRecorder {
+	return o.mgr.GetEventRecorderFor(name)
+}
+
+// RegisterWebhook serves an admission webhook at path on the webhook server of the underlying controller-manager.  The
+// webhook server is started with the Operator, listening on Options.WebhookPort with certificates from
+// Options.WebhookCertDir.
+func (o *Operator) RegisterWebhook(path string, hook http.Handler) {
+	logger.V(1).Info("registering webhook", "path", path)
+	o.mgr.GetWebhookServer().Register(path, hook)
+}
+
+// Serves reports whether the API server serves the type of object.  Optional watches should be guarded by Serves, as
+// a controller watching an unserved type fails to start.
+func (o *Operator) Serves(object client.Object) bool {
+	gvk, err := apiutil.GVKForObject(object, o.mgr.GetScheme())
+	if err != nil {
+		return false
+	}
+
+	if _, err := o.mgr.GetRESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version); err != nil {
+		logger.V(1).Info("type is not served", "gvk", gvk.String(), "error", err.Error())
+		return false
+	}
+	return true
+}
+
 // CreateController registers a new Reconciler with the Operator and associates it with an object type to handle events for.
-func (o *Operator) CreateController(reconciler reconcile.Reconciler, name string, object client.Object) error {
+//
+// Any watches are registered with the controller in addition to object.
+func (o *Operator) CreateController(reconciler reconcile.Reconciler, name string, object client.Object, watches ...Watch) error {
 	log.L.V(1).Info("adding controller", "name", name, "kind", object.GetObjectKind().GroupVersionKind().Kind)
-	bld := builder.ControllerManagedBy(o.mgr)
-	err := bld.For(object).Named(name).Complete(reconciler)
+	bld := builder.ControllerManagedBy(o.mgr).For(object)
+	for _, w := range watches {
+		bld = bld.Watches(&source.Kind{Type: w.Object}, handler.EnqueueRequestsFromMapFunc(w.Mapper))
+	}
+	err := bld.Named(name).Complete(reconciler)
 	if err != nil {
 		logger.Error(err, "failed to create controller", "name", name, "kind", object.GetObjectKind().GroupVersionKind().String())
 		return err
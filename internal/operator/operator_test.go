@@ -2,6 +2,7 @@ package operator
 
 import (
 	"context"
+	"net/http"
 	"sync"
 	"testing"
 
@@ -9,8 +10,11 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	networkingv1beta1 "k8s.io/api/extensions/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/api/meta/testrestmapper"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
@@ -48,7 +52,7 @@ func (f *fakeReconciler) Reconcile(_ context.Context, _ reconcile.Request) (reco
 func Test_NewOperator(t *testing.T) {
 
 	o, err := NewOperator(Options{
-		NameSpace:          "test",
+		Namespaces:         []string{"test"},
 		Client:             clientBuilder,
 		KubeConfig:         &rest.Config{},
 		MapperProvider:     newFakeRestMapper,
@@ -58,12 +62,23 @@ func Test_NewOperator(t *testing.T) {
 		return
 	}
 
-	assert.Equal(t, "test", o.opts.NameSpace)
+	assert.Equal(t, []string{"test"}, o.opts.Namespaces)
 	assert.NoError(t, o.mgr.GetClient().List(context.Background(), &corev1.ServiceList{}))
 
+	o, err = NewOperator(Options{
+		Namespaces:         []string{"test", "other"},
+		Client:             clientBuilder,
+		KubeConfig:         &rest.Config{},
+		MapperProvider:     newFakeRestMapper,
+		MetricsBindAddress: "0",
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"test", "other"}, o.opts.Namespaces)
+	}
+
 	_, err = NewOperator(Options{
-		NameSpace: "test",
-		Client:    clientBuilder,
+		Namespaces: []string{"test"},
+		Client:     clientBuilder,
 	})
 	assert.Error(t, err)
 }
@@ -71,7 +86,7 @@ func Test_NewOperator(t *testing.T) {
 func Test_CreateController(t *testing.T) {
 
 	o, err := NewOperator(Options{
-		NameSpace:          "test",
+		Namespaces:         []string{"test"},
 		Client:             clientBuilder,
 		KubeConfig:         &rest.Config{},
 		MapperProvider:     newFakeRestMapper,
@@ -80,18 +95,28 @@ func Test_CreateController(t *testing.T) {
 
 	assert.NoError(t, err)
 
+	noRequests := func(client.Object) []reconcile.Request { return nil }
+
 	tests := []struct {
-		name string
-		obj  client.Object
-		rec  reconcile.Reconciler
+		name    string
+		obj     client.Object
+		rec     reconcile.Reconciler
+		watches []Watch
 	}{
 		{name: "test-ingress", obj: &networkingv1beta1.Ingress{}, rec: &fakeReconciler{}},
+		{name: "test-ingress-v1", obj: &networkingv1.Ingress{}, rec: &fakeReconciler{}},
 		{name: "test-service", obj: &corev1.Service{}, rec: &fakeReconciler{}},
+		{
+			name:    "test-ingress-watches",
+			obj:     &networkingv1.Ingress{},
+			rec:     &fakeReconciler{},
+			watches: []Watch{{Object: &networkingv1.IngressClass{}, Mapper: noRequests}},
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := o.CreateController(tt.rec, tt.name, tt.obj)
+			err := o.CreateController(tt.rec, tt.name, tt.obj, tt.watches...)
 			assert.NoError(t, err)
 		})
 	}
@@ -116,7 +141,7 @@ func Test_StartController(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	o, err := NewOperator(Options{
-		NameSpace:          "test",
+		Namespaces:         []string{"test"},
 		Client:             clientBuilder,
 		KubeConfig:         &rest.Config{},
 		MapperProvider:     newFakeRestMapper,
@@ -147,3 +172,74 @@ func Test_StartController(t *testing.T) {
 	assert.True(t, r.stopCalled)
 	assert.True(t, r.startCalled)
 }
+
+func Test_GetEventRecorderFor(t *testing.T) {
+	o, err := NewOperator(Options{
+		Namespaces:         []string{"test"},
+		Client:             clientBuilder,
+		KubeConfig:         &rest.Config{},
+		MapperProvider:     newFakeRestMapper,
+		MetricsBindAddress: "0",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.NotNil(t, o.GetEventRecorderFor("test"))
+}
+
+func Test_IndexField(t *testing.T) {
+	o, err := NewOperator(Options{
+		Namespaces:         []string{"test"},
+		Client:             clientBuilder,
+		KubeConfig:         &rest.Config{},
+		MapperProvider:     newFakeRestMapper,
+		MetricsBindAddress: "0",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = o.IndexField(&networkingv1.Ingress{}, "test-index", func(obj client.Object) []string {
+		return []string{obj.GetName()}
+	})
+	assert.NoError(t, err)
+}
+
+func Test_Serves(t *testing.T) {
+	o, err := NewOperator(Options{
+		Namespaces:         []string{"test"},
+		Client:             clientBuilder,
+		KubeConfig:         &rest.Config{},
+		MapperProvider:     newFakeRestMapper,
+		MetricsBindAddress: "0",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.True(t, o.Serves(&corev1.Service{}))
+
+	unserved := &unstructured.Unstructured{}
+	unserved.SetGroupVersionKind(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"})
+	assert.False(t, o.Serves(unserved))
+}
+
+func Test_RegisterWebhook(t *testing.T) {
+	o, err := NewOperator(Options{
+		Namespaces:         []string{"test"},
+		Client:             clientBuilder,
+		KubeConfig:         &rest.Config{},
+		MapperProvider:     newFakeRestMapper,
+		MetricsBindAddress: "0",
+		WebhookPort:        9444,
+		WebhookCertDir:     "/tmp/certs",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	o.RegisterWebhook("/validate", http.NotFoundHandler())
+	assert.Equal(t, 9444, o.mgr.GetWebhookServer().Port)
+	assert.Equal(t, "/tmp/certs", o.mgr.GetWebhookServer().CertDir)
+}
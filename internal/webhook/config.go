@@ -0,0 +1,37 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/pomerium/pomerium-operator/api/v1alpha1"
+	"github.com/pomerium/pomerium-operator/internal/controller"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// ConfigValidatorPath is the path the PomeriumConfig validator is served at
+const ConfigValidatorPath = "/validate-pomerium-io-v1alpha1-pomeriumconfig"
+
+// ConfigValidator implements admission.Handler and rejects PomeriumConfigs whose options are not a valid base
+// configuration
+type ConfigValidator struct{}
+
+// Handle validates the PomeriumConfig in req
+func (v *ConfigValidator) Handle(_ context.Context, req admission.Request) admission.Response {
+	config := &v1alpha1.PomeriumConfig{}
+	if err := json.Unmarshal(req.Object.Raw, config); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	if _, err := controller.BaseConfigFromSpec(config.Spec); err != nil {
+		logger.V(1).Info("denied pomerium config", "name", config.Name, "error", err.Error())
+		return admission.Denied(err.Error())
+	}
+	return admission.Allowed("")
+}
+
+// NewConfigWebhook returns an admission webhook serving ConfigValidator
+func NewConfigWebhook() *admission.Webhook {
+	return &admission.Webhook{Handler: &ConfigValidator{}}
+}
@@ -0,0 +1,62 @@
+package webhook
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func Test_ConfigValidator_Handle(t *testing.T) {
+	tests := []struct {
+		name        string
+		object      string
+		wantAllowed bool
+		wantCode    int32
+	}{
+		{
+			name:        "valid options",
+			object:      `{"metadata": {"name": "pomerium"}, "spec": {"options": {"insecure_server": true, "forward_auth_url": "https://forward.beyondcorp.org"}}}`,
+			wantAllowed: true,
+			wantCode:    200,
+		},
+		{
+			name:        "empty options",
+			object:      `{"metadata": {"name": "pomerium"}, "spec": {}}`,
+			wantAllowed: true,
+			wantCode:    200,
+		},
+		{
+			name:     "invalid option type",
+			object:   `{"metadata": {"name": "pomerium"}, "spec": {"options": {"insecure_server": "not a bool"}}}`,
+			wantCode: 403,
+		},
+		{
+			name:     "options not an object",
+			object:   `{"metadata": {"name": "pomerium"}, "spec": {"options": ["foo"]}}`,
+			wantCode: 403,
+		},
+		{
+			name:     "garbage",
+			object:   `not json`,
+			wantCode: 400,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &ConfigValidator{}
+			resp := v.Handle(context.Background(), admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Operation: admissionv1.Create,
+					Object:    runtime.RawExtension{Raw: []byte(tt.object)},
+				},
+			})
+			assert.Equal(t, tt.wantAllowed, resp.Allowed)
+			assert.Equal(t, tt.wantCode, resp.Result.Code)
+		})
+	}
+}
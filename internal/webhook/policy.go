@@ -0,0 +1,84 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pomerium/pomerium-operator/internal/controller"
+	admissionv1 "k8s.io/api/admission/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// Paths the Ingress and Service policy validators are served at
+const (
+	IngressValidatorPath = "/validate-networking-k8s-io-ingress"
+	ServiceValidatorPath = "/validate-core-v1-service"
+)
+
+// PolicyValidator implements admission.Handler and rejects Ingresses or Services whose pomerium annotations the
+// Reconciler would report as invalid.
+//
+// If FailOpen is set, resources which cannot be validated, e.g. because a policy template could not be read, are
+// allowed.  Otherwise they are rejected.
+type PolicyValidator struct {
+	Reconciler *controller.Reconciler
+	FailOpen   bool
+	newObj     func() client.Object
+}
+
+// Handle validates the Ingress or Service in req
+func (v *PolicyValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Create && req.Operation != admissionv1.Update {
+		return admission.Allowed("")
+	}
+
+	obj := v.newObjFor(req)
+	decoder, err := admission.NewDecoder(scheme.Scheme)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if err := decoder.Decode(req, obj); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	problems, err := v.Reconciler.ValidatePolicy(ctx, obj)
+	if err != nil {
+		logger.Error(err, "could not validate resource", "kind", req.Kind.Kind, "namespace", req.Namespace, "name", req.Name)
+		if v.FailOpen {
+			resp := admission.Allowed("")
+			resp.Warnings = []string{fmt.Sprintf("pomerium annotations were not validated: %s", err)}
+			return resp
+		}
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	if len(problems) > 0 {
+		logger.V(1).Info("denied resource", "kind", req.Kind.Kind, "namespace", req.Namespace, "name", req.Name, "problems", problems)
+		return admission.Denied(strings.Join(problems, "; "))
+	}
+	return admission.Allowed("")
+}
+
+// newObjFor returns the object req is decoded into.  Requests for extensions/v1beta1 Ingresses are decoded as such, as
+// the API server sends them unconverted when the webhook registers that version.
+func (v *PolicyValidator) newObjFor(req admission.Request) client.Object {
+	if req.Kind.Group == extensionsv1beta1.GroupName && req.Kind.Version == "v1beta1" && req.Kind.Kind == "Ingress" {
+		return &extensionsv1beta1.Ingress{}
+	}
+	return v.newObj()
+}
+
+// InjectClient injects c into the Reconciler
+func (v *PolicyValidator) InjectClient(c client.Client) error {
+	return v.Reconciler.InjectClient(c)
+}
+
+// NewPolicyWebhook returns an admission webhook validating newObj typed resources with reconciler
+func NewPolicyWebhook(reconciler *controller.Reconciler, newObj func() client.Object, failOpen bool) *admission.Webhook {
+	return &admission.Webhook{Handler: &PolicyValidator{Reconciler: reconciler, FailOpen: failOpen, newObj: newObj}}
+}
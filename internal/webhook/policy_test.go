@@ -0,0 +1,122 @@
+package webhook
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pomerium/pomerium-operator/internal/configmanager"
+	"github.com/pomerium/pomerium-operator/internal/controller"
+	"github.com/stretchr/testify/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func Test_PolicyValidator_Handle(t *testing.T) {
+	tests := []struct {
+		name        string
+		operation   admissionv1.Operation
+		object      string
+		wantAllowed bool
+		wantCode    int32
+	}{
+		{
+			name:        "valid annotations",
+			operation:   admissionv1.Create,
+			object:      `{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "service", "namespace": "test", "annotations": {"ingress.pomerium.io/from": "https://test.lan.beyondcorp.org"}}, "spec": {"ports": [{"name": "https", "port": 443}]}}`,
+			wantAllowed: true,
+			wantCode:    200,
+		},
+		{
+			name:      "invalid annotation",
+			operation: admissionv1.Update,
+			object:    `{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "service", "namespace": "test", "annotations": {"ingress.pomerium.io/from": "https://test.lan.beyondcorp.org", "ingress.pomerium.io/allowed_groups": "[\"foo\""}}, "spec": {"ports": [{"name": "https", "port": 443}]}}`,
+			wantCode:  403,
+		},
+		{
+			name:        "delete",
+			operation:   admissionv1.Delete,
+			wantAllowed: true,
+			wantCode:    200,
+		},
+		{
+			name:      "garbage",
+			operation: admissionv1.Create,
+			object:    `not json`,
+			wantCode:  400,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewFakeClient()
+			r := controller.NewReconciler(&corev1.Service{}, "pomerium", configmanager.NewConfigManager("test", "test", c, time.Nanosecond*1))
+			hook := NewPolicyWebhook(r, func() client.Object { return &corev1.Service{} }, false)
+			v := hook.Handler.(*PolicyValidator)
+			assert.NoError(t, v.InjectClient(c))
+
+			resp := v.Handle(context.Background(), admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Operation: tt.operation,
+					Object:    runtime.RawExtension{Raw: []byte(tt.object)},
+				},
+			})
+			assert.Equal(t, tt.wantAllowed, resp.Allowed)
+			assert.Equal(t, tt.wantCode, resp.Result.Code)
+		})
+	}
+}
+
+func Test_PolicyValidator_Handle_failOpen(t *testing.T) {
+	object := `{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "service", "namespace": "test", "annotations": {"ingress.pomerium.io/from": "https://test.lan.beyondcorp.org", "ingress.pomerium.io/allowed_groups": "[\"foo\"]"}}, "spec": {"ports": [{"name": "https", "port": 443}]}}`
+
+	for _, failOpen := range []bool{true, false} {
+		c := fake.NewFakeClient()
+		r := controller.NewReconciler(&corev1.Service{}, "pomerium", configmanager.NewConfigManager("test", "test", c, time.Nanosecond*1))
+		// The policy rules ConfigMap does not exist, so the Service cannot be validated
+		r.SetPolicyRules(types.NamespacedName{Name: "missing", Namespace: "pomerium"})
+		hook := NewPolicyWebhook(r, func() client.Object { return &corev1.Service{} }, failOpen)
+		v := hook.Handler.(*PolicyValidator)
+		assert.NoError(t, v.InjectClient(c))
+
+		resp := v.Handle(context.Background(), admission.Request{
+			AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: admissionv1.Create,
+				Object:    runtime.RawExtension{Raw: []byte(object)},
+			},
+		})
+		assert.Equal(t, failOpen, resp.Allowed)
+		if failOpen {
+			assert.Len(t, resp.Warnings, 1)
+		} else {
+			assert.Equal(t, int32(500), resp.Result.Code)
+		}
+	}
+}
+
+func Test_PolicyValidator_Handle_extensionsIngress(t *testing.T) {
+	object := `{"apiVersion": "extensions/v1beta1", "kind": "Ingress", "metadata": {"name": "ingress", "namespace": "test", "annotations": {"kubernetes.io/ingress.class": "pomerium", "ingress.pomerium.io/allowed_groups": "[\"foo\""}}, "spec": {"rules": [{"host": "test.lan.beyondcorp.org"}]}}`
+
+	c := fake.NewFakeClient()
+	r := controller.NewReconciler(&networkingv1.Ingress{}, "pomerium", configmanager.NewConfigManager("test", "test", c, time.Nanosecond*1))
+	hook := NewPolicyWebhook(r, func() client.Object { return &networkingv1.Ingress{} }, false)
+	v := hook.Handler.(*PolicyValidator)
+	assert.NoError(t, v.InjectClient(c))
+
+	resp := v.Handle(context.Background(), admission.Request{
+		AdmissionRequest: admissionv1.AdmissionRequest{
+			Kind:      metav1.GroupVersionKind{Group: "extensions", Version: "v1beta1", Kind: "Ingress"},
+			Operation: admissionv1.Create,
+			Object:    runtime.RawExtension{Raw: []byte(object)},
+		},
+	})
+	assert.False(t, resp.Allowed)
+	assert.Equal(t, int32(403), resp.Result.Code)
+}
@@ -0,0 +1,6 @@
+// Package webhook implements admission webhooks validating resources before they are accepted by the API server
+package webhook
+
+import "github.com/pomerium/pomerium-operator/internal/log"
+
+var logger = log.L.WithValues("component", "webhook")